@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ANALYSIS API
+//
+//	For programs embedding the engine as a library instead of talking UCI.
+//	An Engine is set up with SetPosition and searched with Analyse, which
+//	blocks until a limit is reached or the context is cancelled. Progress is
+//	reported through Engine.Progress while the search runs. Only one analysis
+//	may run on an engine at a time; use separate engines to search in parallel.
+
+var ErrNoLegalMoves = errors.New("position has no legal moves")
+
+// Limits of an analysis. A zero value for a limit means it is not used, and
+// without any limits the search runs until the context is cancelled.
+type Limits struct {
+	Depth    int
+	Nodes    int
+	MoveTime time.Duration
+}
+
+// Score from the point of view of the side to move
+type Score struct {
+	Centipawns int // Normalised like UCI output: 100 means a 50% chance to win
+	Mate       int // Moves until mate, negative when getting mated; 0 if no mate was found
+}
+
+func NewScore(score int) Score {
+	if dist, isMate := mateInMoves(score); isMate {
+		return Score{Mate: dist}
+	}
+	return Score{Centipawns: NormalizeScore(score)}
+}
+
+func (sc Score) String() string {
+	if sc.Mate != 0 {
+		return fmt.Sprintf("mate %d", sc.Mate)
+	}
+	return fmt.Sprintf("cp %d", sc.Centipawns)
+}
+
+// Result of one iteration of the search
+type Analysis struct {
+	Depth    int
+	SelDepth int
+	MultiPV  int // 1 for the best line
+	Score    Score
+	Bound    Bound // Scores of aspiration failures are only bounds
+	PV       []Move
+	Nodes    int
+	Time     time.Duration
+}
+
+// Returns the first move of the PV, or an empty move without a PV
+func (a Analysis) BestMove() Move {
+	if len(a.PV) == 0 {
+		return Move{}
+	}
+	return a.PV[0]
+}
+
+// Sets up a position from a FEN and the moves played from it. An empty fen
+// or "startpos" means the starting position. Moves are given in UCI notation
+// and must be legal.
+func NewPosition(fen string, moves ...string) (*Board, error) {
+	b := NewBoard()
+	if fen == "" || fen == "startpos" {
+		b.InitStartPos()
+	} else {
+		parsed, err := ParseFEN(fen)
+		if err != nil {
+			return nil, err
+		}
+		b = parsed
+	}
+
+	for _, uci := range moves {
+		mv, err := LegalMoveFromUCI(uci, b)
+		if err != nil {
+			return nil, err
+		}
+		b.MakeMove(mv)
+	}
+	return b, nil
+}
+
+// Sets up the position of the main searcher, see NewPosition. On error the
+// position is left unchanged.
+func (e *Engine) SetPosition(fen string, moves ...string) error {
+	b, err := NewPosition(fen, moves...)
+	if err != nil {
+		return err
+	}
+	e.MainSearcher().Position = b
+	return nil
+}
+
+// Searches the current position and returns the deepest completed best line.
+// Cancelling the context stops the search early; its error is only returned
+// if not even the first iteration completed. Progress is passed to
+// e.Progress, if set, from the goroutine running Analyse.
+func (e *Engine) Analyse(ctx context.Context, limits Limits) (Analysis, error) {
+	if err := ctx.Err(); err != nil {
+		return Analysis{}, err
+	}
+
+	s := e.MainSearcher()
+	if len(s.Position.GenerateLegalMoves()) == 0 {
+		return Analysis{}, ErrNoLegalMoves
+	}
+
+	// Protocol output is only silenced for this search, the engine may be shared with a protocol
+	result := Analysis{}
+	wasQuiet := s.Info.Quiet
+	s.Info.Quiet = true
+	s.Info.Progress = func(a Analysis) {
+		if a.MultiPV == 1 && a.Bound == EXACT {
+			result = a
+		}
+		if e.Progress != nil {
+			e.Progress(a)
+		}
+	}
+	defer func() {
+		s.Info.Progress = nil
+		s.Info.Quiet = wasQuiet
+	}()
+
+	infinite := limits.Depth <= 0 && limits.Nodes <= 0 && limits.MoveTime <= 0
+	e.Timer.Calculate(s.Position.turn, 0, 0, 0, 0, 0, int64(limits.Depth), int64(limits.Nodes), limits.MoveTime.Milliseconds(), infinite)
+
+	// Stop the search when the context is done
+	e.Timer.StopOn(ctx.Done())
+	defer e.Timer.StopOn(nil)
+
+	best := s.iterativeDeepening()
+	if len(result.PV) == 0 {
+		if err := ctx.Err(); err != nil {
+			return Analysis{}, err
+		}
+		result.PV = []Move{best}
+	}
+	return result, nil
+}
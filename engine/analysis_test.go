@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSetPosition(t *testing.T) {
+	e := NewEngine(1)
+
+	if err := e.SetPosition("startpos", "e2e4", "e7e5", "g1f3"); err != nil {
+		t.Fatalf("TestSetPosition (moves): %v", err)
+	}
+	if got := e.MainSearcher().Position.turn; got != BLACK {
+		t.Errorf("TestSetPosition (moves): got %d to move, wanted black", got)
+	}
+
+	if err := e.SetPosition("startpos", "e2e5"); err == nil {
+		t.Errorf("TestSetPosition (illegal): accepted an illegal move")
+	}
+	if err := e.SetPosition("8/8/8/8 w - -"); err == nil {
+		t.Errorf("TestSetPosition (fen): accepted a truncated fen")
+	}
+}
+
+func TestAnalyse(t *testing.T) {
+	e := NewEngine(16)
+	if err := e.SetPosition("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3"); err != nil {
+		t.Fatal(err)
+	}
+
+	depths := []int{}
+	e.Progress = func(a Analysis) {
+		if a.MultiPV == 1 && a.Bound == EXACT {
+			depths = append(depths, a.Depth)
+		}
+	}
+
+	result, err := e.Analyse(context.Background(), Limits{Depth: 6})
+	if err != nil {
+		t.Fatalf("TestAnalyse: %v", err)
+	}
+	if result.Depth != 6 || len(result.PV) == 0 || result.Nodes == 0 || result.Bound != EXACT {
+		t.Errorf("TestAnalyse: got depth %d pv %v nodes %d bound %d", result.Depth, result.PV, result.Nodes, result.Bound)
+	}
+	if len(depths) != 6 || depths[5] != 6 {
+		t.Errorf("TestAnalyse (progress): got iterations %v, wanted 1 to 6", depths)
+	}
+	if e.MainSearcher().Info.Quiet {
+		t.Errorf("TestAnalyse: the searcher stayed quiet after the analysis")
+	}
+
+	// Mate in one is reported as a mate score
+	e.Progress = nil
+	e.SetPosition("6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	result, err = e.Analyse(context.Background(), Limits{Depth: 4})
+	if err != nil || result.Score.Mate <= 0 || result.BestMove().ToUCI() != "a1a8" {
+		t.Errorf("TestAnalyse (mate): got %s %s, err %v", result.Score, result.BestMove(), err)
+	}
+
+	// Checkmated positions have nothing to analyse
+	e.SetPosition("R5k1/5ppp/8/8/8/8/8/6K1 b - - 0 1")
+	if _, err := e.Analyse(context.Background(), Limits{Depth: 4}); err != ErrNoLegalMoves {
+		t.Errorf("TestAnalyse (mated): got error %v, wanted %v", err, ErrNoLegalMoves)
+	}
+}
+
+func TestAnalyseCancel(t *testing.T) {
+	e := NewEngine(16)
+	e.SetPosition("startpos")
+
+	// Without limits, only the context stops the search
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result, err := e.Analyse(ctx, Limits{})
+	if err != nil || len(result.PV) == 0 {
+		t.Fatalf("TestAnalyseCancel: got pv %v, err %v", result.PV, err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("TestAnalyseCancel: search ran for %s after cancellation", elapsed)
+	}
+
+	// An already cancelled context does not search at all
+	if _, err := e.Analyse(ctx, Limits{Depth: 1}); err != context.DeadlineExceeded {
+		t.Errorf("TestAnalyseCancel (cancelled): got error %v, wanted %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// BENCH
+//
+//	`bench` searches a fixed set of positions to a fixed depth on a fresh engine and reports
+//	the total node count and speed. The node count is a signature of the search: a change that
+//	alters it changes how the engine plays, while a pure speedup keeps it the same. With the
+//	stats build tag the search statistics of the run are printed afterwards.
+//
+//	`bench [depth] pseudo` runs the same positions with pseudo-legal move generation, see
+//	movegen.go, to compare the speed of both modes. Equal moves are picked in another order
+//	there, so its node count is a different signature than the default one.
+var BENCH_FENS = []string{
+	"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+	"r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10",
+	"r1bq1rk1/pp2bppp/2n1pn2/2pp4/3P4/2PBPN2/PP1N1PPP/R1BQ1RK1 w - - 0 8",
+	"2r2rk1/pp1bqppp/2n1pn2/3p4/3P4/P1NBPN2/1PQ2PPP/2R2RK1 b - - 3 14",
+	"r1b2rk1/2q1bppp/p2p1n2/np2p3/3PP3/5N1P/PPBN1PP1/R1BQR1K1 b - - 0 12",
+	"4rrk1/pp1n3p/3q2pQ/2p1pb2/2PP4/2P3N1/P2B2PP/4RRK1 b - - 7 19",
+	"6k1/6p1/6Pp/ppp5/3pn2P/1P3K2/1PP2P2/3N4 b - - 0 1",
+	"8/8/1p1k4/5ppp/PPK1p3/6PP/8/8 b - - 0 1",
+	"5k2/7R/4P2p/5K2/p1r2P1p/8/8/8 b - - 0 1",
+	"3r2k1/1p3ppp/2pq4/p1n5/P6P/1P6/1PB2QP1/1K2R3 w - - 0 1",
+}
+
+const BENCH_DEPTH = 10
+const BENCH_HASH = 16
+
+// Runs the bench on a new engine, printing a line per position and the
+// totals to w. Returns the total number of nodes searched.
+func RunBench(depth int, pseudoLegal bool, w io.Writer) int {
+	e := NewEngine(BENCH_HASH)
+	e.PseudoLegal = pseudoLegal
+	s := e.MainSearcher()
+
+	totalNodes := 0
+	start := time.Now()
+	for i, fen := range BENCH_FENS {
+		e.NewGame()
+		b, err := NewPosition(fen)
+		if err != nil {
+			panic(err)
+		}
+		s.Position = b
+		if _, err := e.Analyse(context.Background(), Limits{Depth: depth}); err != nil {
+			panic(err)
+		}
+		totalNodes += s.Info.NodesSearched
+		fmt.Fprintf(w, "position %d/%d: %d nodes\n", i+1, len(BENCH_FENS), s.Info.NodesSearched)
+	}
+	elapsed := time.Since(start)
+
+	if STATS_ENABLED {
+		s.Stats.Print(w)
+	}
+	fmt.Fprintf(w, "%d nodes %d nps\n", totalNodes, int64(float64(totalNodes)/max(elapsed.Seconds(), 0.001)))
+	return totalNodes
+}
+
+// Parses the optional depth and mode arguments of `bench`
+func benchArgs(args []string) (int, bool, error) {
+	depth, pseudoLegal := BENCH_DEPTH, false
+	if len(args) > 0 && args[len(args)-1] == "pseudo" {
+		pseudoLegal = true
+		args = args[:len(args)-1]
+	}
+	if len(args) > 1 {
+		return 0, false, fmt.Errorf("usage: bench [depth] [pseudo]")
+	}
+	if len(args) == 1 {
+		var err error
+		depth, err = strconv.Atoi(args[0])
+		if err != nil || depth < 1 || depth > MAX_DEPTH {
+			return 0, false, fmt.Errorf("bench depth must be between 1 and %d", MAX_DEPTH)
+		}
+	}
+	return depth, pseudoLegal, nil
+}
+
+// Entry point for `maelstrom bench [depth] [pseudo]`
+func RunBenchCommand(args []string) error {
+	depth, pseudoLegal, err := benchArgs(args)
+	if err != nil {
+		return err
+	}
+	RunBench(depth, pseudoLegal, Output)
+	return nil
+}
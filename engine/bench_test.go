@@ -0,0 +1,36 @@
+package engine
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestBenchIsDeterministic(t *testing.T) {
+	for _, pseudoLegal := range []bool{false, true} {
+		first := RunBench(5, pseudoLegal, io.Discard)
+		second := RunBench(5, pseudoLegal, io.Discard)
+		if first == 0 || first != second {
+			t.Errorf("TestBenchIsDeterministic (pseudo-legal %t): got %d and %d nodes", pseudoLegal, first, second)
+		}
+	}
+}
+
+// Compares the speed of legal and pseudo-legal move generation on the bench
+// positions: go test -run XXX -bench BenchmarkBench ./engine
+func BenchmarkBench(b *testing.B) {
+	for _, mode := range []struct {
+		name        string
+		pseudoLegal bool
+	}{{"legal", false}, {"pseudo", true}} {
+		b.Run(mode.name, func(b *testing.B) {
+			nodes := 0
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				nodes += RunBench(8, mode.pseudoLegal, io.Discard)
+			}
+			b.ReportMetric(float64(nodes)/time.Since(start).Seconds(), "nps")
+			b.ReportMetric(float64(nodes)/float64(b.N), "nodes/op")
+		})
+	}
+}
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 )
@@ -9,42 +10,41 @@ import (
 type u64 uint64
 
 type Board struct {
-	pieces           [14]u64               // Stores bitboards of all white and black pieces
-	squares          [64]Piece             // Stores all 64 squares (not used for move generation)
-	colors           [2]u64                // Stores bitboards of both colors
-	occupied         u64                   // Bits are set when pieces are there
-	empty            u64                   // Bits are clear when pieces are there
-	turn             Color                 // Side to move
-	enPassant        Square                // En passant square. If not possible, stores EMPTY
-	OO               bool                  // If kingside castling available for White
-	OOO              bool                  // If queenside castling is available for White
-	oo               bool                  // If kingside castling is available for Black
-	ooo              bool                  // If queenside castling is available for Black
-	castlingRights   uint8                 // Combines all castling rights into index from 0 to 16 for castling hash
-	history          []prev                // Stores history for board
-	zobrist          u64                   // Zobrist hash (TODO)
-	plyCnt           int                   // Stores number of half moves played
-	plyCnt50         int                   // Stores number of half moves played since a capture (for 50-move rule)
-	moveCount        int                   // Stores which move currently we are at
-	whiteCastled     bool                  // Stores whether white has previously castled
-	blackCastled     bool                  // Stores whether black has previously castled
-	accumulatorStack [1000]AccumulatorPair // Stores stack of NNUE accumulators
-	accumulatorIdx   int                   // Stores the index of current accumulator in the stack
+	pieces           [14]u64           // Stores bitboards of all white and black pieces
+	squares          [64]Piece         // Stores all 64 squares (not used for move generation)
+	colors           [2]u64            // Stores bitboards of both colors
+	occupied         u64               // Bits are set when pieces are there
+	empty            u64               // Bits are clear when pieces are there
+	turn             Color             // Side to move
+	enPassant        Square            // En passant square. If not possible, stores EMPTY
+	OO               bool              // If kingside castling available for White
+	OOO              bool              // If queenside castling is available for White
+	oo               bool              // If kingside castling is available for Black
+	ooo              bool              // If queenside castling is available for Black
+	castlingRights   uint8             // Combines all castling rights into index from 0 to 16 for castling hash
+	history          []prev            // Stores history for board
+	zobrist          u64               // Zobrist hash (TODO)
+	plyCnt           int               // Stores number of half moves played
+	plyCnt50         int               // Stores number of half moves played since a capture (for 50-move rule)
+	moveCount        int               // Stores which move currently we are at
+	whiteCastled     bool              // Stores whether white has previously castled
+	blackCastled     bool              // Stores whether black has previously castled
+	accumulatorStack []AccumulatorPair // Stores NNUE accumulators of the moves made since ResetAccumulators
+	accumulatorIdx   int               // Stores the index of current accumulator in the stack
 }
 
 type prev struct {
-	move               Move   // Stores previous move made
-	OO                 bool   // White Kingside castling history
-	OOO                bool   // White Queenside castling history
-	oo                 bool   // Black Kingside castling history
-	ooo                bool   // Black Queenside castling history
-	castlingRights     uint8  // Combines all castling rights into index from 0 to 16 for castling hash
-	enPassant          Square // En passant square history
-	hash               u64    // Zobrist hash of prev position
-	whiteCastled       bool   // Stores whether white has previously castled
-	blackCastled       bool   // Stores whether black has previously castled
-	plyCnt50           int    // Stores number of half moves played since a capture (for 50-move rule)
-	prevAccumulatorIdx int    // Stores index of previous accumulator in the stack
+	move           Move   // Stores previous move made
+	OO             bool   // White Kingside castling history
+	OOO            bool   // White Queenside castling history
+	oo             bool   // Black Kingside castling history
+	ooo            bool   // Black Queenside castling history
+	castlingRights uint8  // Combines all castling rights into index from 0 to 16 for castling hash
+	enPassant      Square // En passant square history
+	hash           u64    // Zobrist hash of prev position
+	whiteCastled   bool   // Stores whether white has previously castled
+	blackCastled   bool   // Stores whether black has previously castled
+	plyCnt50       int    // Stores number of half moves played since a capture (for 50-move rule)
 }
 
 func NewBoard() *Board {
@@ -83,9 +83,27 @@ func (b *Board) InitStartPos() {
 
 	b.zobrist ^= CASTLING_HASH[b.castlingRights]
 
-	b.accumulatorStack[b.accumulatorIdx] = GlobalNNUE.RecomputeAccumulators(b)
+	b.ResetAccumulators(&GlobalNNUE)
 }
 
+// The accumulator stack starts out large enough for most searches and grows
+// when a search goes deeper
+const ACCUMULATOR_STACK_SIZE = 128
+
+// Recomputes the accumulators of the current position with the given network
+// and makes them the bottom of the stack. Only the moves made from here on
+// keep an accumulator, so the stack is as deep as the search rather than the
+// game. The search resets it at the root.
+func (b *Board) ResetAccumulators(n *NNUE) {
+	if b.accumulatorStack == nil {
+		b.accumulatorStack = make([]AccumulatorPair, 1, ACCUMULATOR_STACK_SIZE)
+	}
+	b.accumulatorStack = b.accumulatorStack[:1]
+	b.accumulatorIdx = 0
+	b.accumulatorStack[0] = n.RecomputeAccumulators(b)
+}
+
+// Sets up the position without validating the FEN, see ParseFEN
 func (b *Board) InitFEN(fen string) {
 	b.zobrist = 0
 	for s := A1; s <= H8; s++ {
@@ -164,7 +182,7 @@ func (b *Board) InitFEN(fen string) {
 
 	b.plyCnt = b.moveCount * 2
 
-	b.accumulatorStack[b.accumulatorIdx] = GlobalNNUE.RecomputeAccumulators(b)
+	b.ResetAccumulators(&GlobalNNUE)
 }
 
 func (b *Board) GetColorPieces(p PieceType, c Color) u64 {
@@ -246,8 +264,15 @@ func (b *Board) removePiece(p Piece, sq Square, c Color) {
 	b.zobrist ^= ZOBRIST_TABLE[p][sq]
 }
 
-func (b *Board) MakeMoveFromUCI(uci string) {
-	b.MakeMove(FromUCI(uci, b))
+// Plays a move given in UCI notation, leaving the board unchanged if the move
+// is malformed or illegal
+func (b *Board) MakeMoveFromUCI(uci string) error {
+	mv, err := LegalMoveFromUCI(uci, b)
+	if err != nil {
+		return err
+	}
+	b.MakeMove(mv)
+	return nil
 }
 
 func (b *Board) MakeMoveNoUpdate(mv Move) {
@@ -294,13 +319,15 @@ func (b *Board) MakeMove(mv Move) {
 		enPassant:    b.enPassant,
 		hash:         b.zobrist,
 		whiteCastled: b.whiteCastled, blackCastled: b.blackCastled,
-		plyCnt50:           b.plyCnt50,
-		prevAccumulatorIdx: b.accumulatorIdx,
+		plyCnt50: b.plyCnt50,
 	}
 
 	b.history = append(b.history, entry)
 	b.plyCnt50++
 	b.accumulatorIdx++
+	if b.accumulatorIdx == len(b.accumulatorStack) {
+		b.accumulatorStack = append(b.accumulatorStack, AccumulatorPair{})
+	}
 
 	StoreAccUpdatesOnMove(b, mv, b.turn)
 
@@ -430,6 +457,10 @@ func (b *Board) MakeMove(mv Move) {
 
 	b.turn = ReverseColor(b.turn)
 	b.plyCnt++
+
+	if DEBUG {
+		b.debugCheck("MakeMove", mv)
+	}
 }
 
 func (b *Board) UndoNoUpdate(prevMove Move) {
@@ -527,10 +558,21 @@ func (b *Board) Undo() {
 	b.whiteCastled = prevEntry.whiteCastled
 	b.blackCastled = prevEntry.blackCastled
 	b.plyCnt50 = prevEntry.plyCnt50
-	b.accumulatorIdx = prevEntry.prevAccumulatorIdx
 
 	b.history = b.history[:len(b.history)-1]
 	b.plyCnt--
+
+	// Moves made before the stack was reset have no accumulator to go back to,
+	// the next evaluation recomputes it with its own network
+	if b.accumulatorIdx > 0 {
+		b.accumulatorIdx--
+	} else {
+		b.accumulatorStack[0] = AccumulatorPair{stale: true}
+	}
+
+	if DEBUG {
+		b.debugCheck("Undo", prevMove)
+	}
 }
 
 func (b *Board) MakeNullMove() {
@@ -650,7 +692,8 @@ func (b *Board) Print() {
 	fmt.Print(s)
 }
 
-func (b *Board) PrintFromBitBoards() {
+// Draws the board to w
+func (b *Board) PrintFromBitBoards(w io.Writer) {
 	s := "   +---+---+---+---+---+---+---+---+\n"
 	for i := 56; i >= 0; i -= 8 {
 		s += " " + fmt.Sprint(i/8+1) + " "
@@ -660,7 +703,7 @@ func (b *Board) PrintFromBitBoards() {
 				for k := 0; k < 14; k++ {
 					if b.pieces[k]&u64(1<<(i+j)) != 0 {
 						if found {
-							fmt.Println("Duplicate pieces...")
+							fmt.Fprintln(w, "Duplicate pieces...")
 						} else {
 							found = true
 							s += "| " + Piece(k).ToString() + " "
@@ -668,12 +711,12 @@ func (b *Board) PrintFromBitBoards() {
 					}
 				}
 				if !found {
-					fmt.Println("Piece is in occupied bitboard not not present in any of the pieces bitboard...")
+					fmt.Fprintln(w, "Piece is in occupied bitboard not not present in any of the pieces bitboard...")
 				}
 			} else if b.empty&u64(1<<(i+j)) != 0 {
 				s += "| " + EMPTY.ToString() + " "
 			} else {
-				fmt.Println("Square is not represented in either occupied or empty...")
+				fmt.Fprintln(w, "Square is not represented in either occupied or empty...")
 			}
 		}
 		s += "| " + "\n"
@@ -681,7 +724,7 @@ func (b *Board) PrintFromBitBoards() {
 	}
 
 	s += "     A   B   C   D   E   F   G   H\n"
-	fmt.Print(s)
+	fmt.Fprint(w, s)
 }
 
 // For testing purposes only
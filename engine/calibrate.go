@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// TT size in MB for each player in calibration games
+const CALIBRATION_HASH = 16
+
+// Result of a match between two adjacent levels of the skill ladder
+type LadderMatch struct {
+	Lower   float64
+	Upper   float64
+	Games   int
+	Score   float64 // Points scored by the upper level
+	EloDiff float64 // Estimated Elo of the upper level over the lower level
+}
+
+// Converts a match score (fraction of points) to an Elo difference. Scores
+// are clamped away from 0 and 1 so that a clean sweep gives a finite value.
+func eloFromScore(score float64, games int) float64 {
+	margin := 0.5 / float64(games)
+	score = math.Max(margin, math.Min(score, 1-margin))
+	return -400 * math.Log10(1/score-1)
+}
+
+// Plays each pair of adjacent levels against each other with alternating
+// colors. nodes is the per-move budget of the full strength engine; limited
+// levels are additionally capped by their own limits.
+func CalibrateSkill(levels []float64, games int, nodes int, seed int64) []LadderMatch {
+	rng := rand.New(rand.NewSource(seed))
+	matches := []LadderMatch{}
+
+	for i := 1; i < len(levels); i++ {
+		// Separate engines keep the players from sharing a TT
+		lower, upper := NewEngine(CALIBRATION_HASH).MainSearcher(), NewEngine(CALIBRATION_HASH).MainSearcher()
+		lower.Skill.SetLevel(levels[i-1])
+		lower.Skill.Seed(rng.Int63())
+		upper.Skill.SetLevel(levels[i])
+		upper.Skill.Seed(rng.Int63())
+
+		match := LadderMatch{Lower: levels[i-1], Upper: levels[i], Games: games}
+		for game := 0; game < games; game++ {
+			if game%2 == 0 {
+				_, result := PlayGame(upper, lower, nodes, rng)
+				match.Score += result
+			} else {
+				_, result := PlayGame(lower, upper, nodes, rng)
+				match.Score += 1 - result
+			}
+		}
+
+		match.Score /= float64(games)
+		match.EloDiff = eloFromScore(match.Score, games)
+		matches = append(matches, match)
+
+		fmt.Fprintf(Output, "info string level %g vs %g: %.1f%% (%+.0f elo)\n", match.Upper, match.Lower, 100*match.Score, match.EloDiff)
+	}
+
+	return matches
+}
+
+// Entry point for `maelstrom calibrate`. Measures the Elo gaps between skill
+// levels, prints a ladder that can be pasted into SKILL_ELO_LADDER and fails
+// if a level does not beat the one below it.
+func RunCalibrate(args []string) error {
+	flags := flag.NewFlagSet("calibrate", flag.ContinueOnError)
+	levelList := flags.String("levels", "0,4,8,12,16,19", "comma separated skill levels, weakest first")
+	games := flags.Int("games", 20, "games per pair of adjacent levels")
+	nodes := flags.Int("nodes", 200000, "nodes per move at full strength")
+	seed := flags.Int64("seed", 1, "random seed for openings and move selection")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	levels := []float64{}
+	for _, field := range strings.Split(*levelList, ",") {
+		level, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || level < 0 || level > MAX_SKILL_LEVEL {
+			return fmt.Errorf("invalid skill level %q", field)
+		}
+		if len(levels) > 0 && level <= levels[len(levels)-1] {
+			return fmt.Errorf("skill levels must be increasing")
+		}
+		levels = append(levels, level)
+	}
+	if len(levels) < 2 {
+		return fmt.Errorf("need at least two skill levels")
+	}
+	if *games < 1 {
+		return fmt.Errorf("need at least one game per pair")
+	}
+
+	matches := CalibrateSkill(levels, *games, *nodes, *seed)
+
+	// Anchor the strongest level at SKILL_MAX_ELO and walk down the ladder
+	elos := make([]float64, len(levels))
+	elos[len(levels)-1] = SKILL_MAX_ELO
+	for i := len(matches) - 1; i >= 0; i-- {
+		elos[i] = elos[i+1] - matches[i].EloDiff
+	}
+
+	fmt.Fprintln(Output, "var SKILL_ELO_LADDER = []SkillRating{")
+	for i, level := range levels {
+		fmt.Fprintf(Output, "\t{%g, %d},\n", level, int(math.Round(elos[i])))
+	}
+	fmt.Fprintln(Output, "}")
+
+	for _, match := range matches {
+		if match.EloDiff <= 0 {
+			return fmt.Errorf("ladder is not monotonic: level %g scored %.1f%% against level %g", match.Upper, 100*match.Score, match.Lower)
+		}
+	}
+
+	return nil
+}
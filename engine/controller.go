@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"context"
+	"sync"
+)
+
+// SEARCH CONTROLLER
+//
+//	Runs searches in the background, one at a time. Starting a search stops and waits for the
+//	previous one, and commands that change the position, options or tables must call StopAndWait
+//	first so that they never touch state a running search is using. Each search gets a context
+//	that is cancelled by Stop; searches should pass its Done channel to TimeManager.StopOn.
+//	The zero value is ready to use.
+type SearchController struct {
+	mu      sync.Mutex
+	running sync.WaitGroup
+	cancel  context.CancelFunc // Cancels the context of the current search, nil if none was started
+}
+
+// Starts search in a new goroutine once the previous search has finished
+func (c *SearchController) Start(search func(ctx context.Context)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopAndWait()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		defer cancel()
+		search(ctx)
+	}()
+}
+
+// Asks the current search to stop without waiting for it
+func (c *SearchController) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// Stops the current search and returns once it has finished
+func (c *SearchController) StopAndWait() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopAndWait()
+}
+
+func (c *SearchController) stopAndWait() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.running.Wait()
+}
+
+// Returns once the current search has finished on its own. Must not be
+// called concurrently with Start.
+func (c *SearchController) Wait() {
+	c.running.Wait()
+}
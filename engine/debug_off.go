@@ -0,0 +1,8 @@
+//go:build !debug
+
+package engine
+
+const DEBUG = false
+
+// Without the debug build tag the board is not checked, see debug_on.go
+func (b *Board) debugCheck(action string, mv Move) {}
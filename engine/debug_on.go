@@ -0,0 +1,98 @@
+//go:build debug
+
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CONSISTENCY CHECKS
+//
+//	Building with `-tags debug` checks the whole board after every MakeMove and Undo: the hash
+//	against one computed from scratch, the piece bitboards, colours, occupancy and the square
+//	array against each other, the castling rights against the kings and rooks, and the NNUE
+//	accumulator against a full recompute. The first mismatch panics with the position and the
+//	moves that led to it, close to the bug rather than many nodes later in the search. This makes
+//	the engine many times slower, so it is only meant for tests: `go test -tags debug ./...`.
+const DEBUG = true
+
+// Panics if the incremental state of the board is inconsistent
+func (b *Board) debugCheck(action string, mv Move) {
+	if err := b.checkConsistency(); err != nil {
+		trail := []string{}
+		for _, entry := range b.history {
+			trail = append(trail, ternary(entry.move.null, "0000", entry.move.ToUCI()))
+		}
+		panic(fmt.Sprintf("%s %s: %v\nfen: %s\nmoves: %s", action, ternary(mv.null, "0000", mv.ToUCI()), err, b.ToFEN(), strings.Join(trail, " ")))
+	}
+}
+
+func (b *Board) checkConsistency() error {
+	colors := [2]u64{}
+	for p := W_P; p < EMPTY; p++ {
+		colors[p.GetColor()] |= b.pieces[p]
+		for sq := A1; sq <= H8; sq++ {
+			if (b.pieces[p]&SQUARE_TO_BITBOARD[sq] != 0) != (b.squares[sq] == p) {
+				return fmt.Errorf("bitboard of %s disagrees with the square array on %s", p.ToString(), SQUARE_TO_STRING_MAP[sq])
+			}
+		}
+	}
+	if colors != b.colors {
+		return fmt.Errorf("colour bitboards %#x do not match the pieces %#x", b.colors, colors)
+	}
+	if b.occupied != colors[WHITE]|colors[BLACK] || b.empty != ^b.occupied {
+		return fmt.Errorf("occupancy %#x and empty %#x do not match the pieces", b.occupied, b.empty)
+	}
+	if PopCount(b.pieces[W_K]) != 1 || PopCount(b.pieces[B_K]) != 1 {
+		return fmt.Errorf("each side needs exactly one king")
+	}
+
+	flags := [4]struct {
+		right   uint8
+		allowed bool
+	}{{WHITE_K_CASTLE, b.OO}, {WHITE_Q_CASTLE, b.OOO}, {BLACK_K_CASTLE, b.oo}, {BLACK_Q_CASTLE, b.ooo}}
+	for _, flag := range flags {
+		if (b.castlingRights&flag.right != 0) != flag.allowed {
+			return fmt.Errorf("castling rights %04b disagree with the castling flags", b.castlingRights)
+		}
+	}
+	for i, right := range "KQkq" {
+		if b.castlingRights&(1<<i) == 0 {
+			continue
+		}
+		for _, p := range FEN_CASTLING_PIECES[right] {
+			if b.squares[p.square] != stringToPieceMap[string(p.piece)] {
+				return fmt.Errorf("castling right %c without %c on %s", right, p.piece, SQUARE_TO_STRING_MAP[p.square])
+			}
+		}
+	}
+
+	if hash := b.computeHash(); hash != b.zobrist {
+		return fmt.Errorf("hash %#x differs from the recomputed %#x", b.zobrist, hash)
+	}
+
+	GlobalNNUE.ApplyLazyUpdates(b)
+	accs, want := b.accumulatorStack[b.accumulatorIdx], GlobalNNUE.RecomputeAccumulators(b)
+	if accs.white != want.white || accs.black != want.black {
+		return fmt.Errorf("accumulator differs from the recomputed one")
+	}
+	return nil
+}
+
+// Hash of the position computed from scratch
+func (b *Board) computeHash() u64 {
+	hash := CASTLING_HASH[b.castlingRights]
+	for sq := A1; sq <= H8; sq++ {
+		if b.squares[sq] != EMPTY {
+			hash ^= ZOBRIST_TABLE[b.squares[sq]][sq]
+		}
+	}
+	if b.enPassant != EMPTY_SQ {
+		hash ^= EP_FILE_HASH[SquareToFile(b.enPassant)]
+	}
+	if b.turn == BLACK {
+		hash ^= TURN_HASH
+	}
+	return hash
+}
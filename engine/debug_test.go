@@ -0,0 +1,36 @@
+//go:build debug
+
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDebugCheckCatchesCorruption(t *testing.T) {
+	InitializeEverythingExceptTTable()
+
+	corrupt := map[string]func(b *Board){
+		"hash":      func(b *Board) { b.zobrist ^= 1 },
+		"bitboard":  func(b *Board) { b.pieces[W_N] |= SQUARE_TO_BITBOARD[E4] },
+		"occupancy": func(b *Board) { b.empty |= SQUARE_TO_BITBOARD[A1] },
+		"castling":  func(b *Board) { b.castlingRights &^= WHITE_K_CASTLE },
+		"accumulator": func(b *Board) {
+			b.accumulatorStack[b.accumulatorIdx].white.values[0]++
+		},
+	}
+	for name, change := range corrupt {
+		b := mustPosition(t, "startpos", "e2e4", "e7e5")
+		change(b)
+
+		message := func() (message string) {
+			defer func() { message = fmt.Sprint(recover()) }()
+			b.MakeMoveFromUCI("g1f3")
+			return ""
+		}()
+		if !strings.Contains(message, "MakeMove g1f3") || !strings.Contains(message, "moves: e2e4 e7e5 g1f3") {
+			t.Errorf("TestDebugCheckCatchesCorruption (%s): got %q", name, message)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"sync"
+)
+
+// Engine bundles everything a search needs: the transposition table, the
+// time manager, the network, the tunable parameters, the LMR table and the
+// searchers using them. Engines share nothing mutable, so several of them
+// can search at the same time in one process.
+type Engine struct {
+	TT        *TranspositionTable
+	Timer     *TimeManager
+	NNUE      *NNUE
+	Params    *TunableParameters
+	LMR       *[101][101]int
+	Searchers []*Searcher    // Searchers[0] is the main search thread
+	Progress  func(Analysis) // Receives the progress of Analyse, see analysis.go
+
+	// Generates moves pseudo-legally in the search and checks each move as
+	// it is picked, see movegen.go. Off by default.
+	PseudoLegal bool
+}
+
+// The default instance is made up of the package globals (TT, Timer,
+// GlobalNNUE, Params and LMR_TABLE), which are kept for compatibility with
+// code written before engines existed.
+var DefaultEngine = &Engine{
+	TT:     &TT,
+	Timer:  &Timer,
+	NNUE:   &GlobalNNUE,
+	Params: &Params,
+	LMR:    &LMR_TABLE,
+}
+
+// Attack tables, zobrist keys and the default network are shared read-only
+// by all engines and only need to be set up once
+var initOnce sync.Once
+
+// Creates an engine with its own transposition table of the given size in
+// megabytes and a copy of the default parameters. The weights of the default
+// network are shared since they are never written to.
+func NewEngine(hashMB int) *Engine {
+	initOnce.Do(InitializeEverythingExceptTTable)
+
+	params := DEFAULT_PARAMS
+	e := &Engine{
+		TT:     &TranspositionTable{},
+		Timer:  &TimeManager{moveOverhead: DEFAULT_MOVE_OVERHEAD},
+		NNUE:   &GlobalNNUE,
+		Params: &params,
+		LMR:    &[101][101]int{},
+	}
+	e.Timer.params = e.Params
+	e.TT.Resize(hashMB)
+	initLMRTable(e.LMR)
+	e.NewSearcher()
+	return e
+}
+
+// Creates a searcher attached to this engine
+func (e *Engine) NewSearcher() *Searcher {
+	s := &Searcher{}
+	s.SetEngine(e)
+	s.Position = NewBoard()
+	e.Searchers = append(e.Searchers, s)
+	return s
+}
+
+// Returns the main search thread, creating it if needed
+func (e *Engine) MainSearcher() *Searcher {
+	if len(e.Searchers) == 0 {
+		return e.NewSearcher()
+	}
+	return e.Searchers[0]
+}
+
+// Resets everything learned during a game
+func (e *Engine) NewGame() {
+	for _, s := range e.Searchers {
+		s.ClearHistory()
+		s.ClearContHist()
+		s.ClearKillers()
+		s.ClearCounters()
+	}
+	e.TT.Clear()
+	e.Timer.NewGame()
+}
+
+// Attaches the searcher to an engine, whose tables it will use from now on
+func (s *Searcher) SetEngine(e *Engine) {
+	s.engine = e
+	s.tt = e.TT
+	s.timer = e.Timer
+	s.nnue = e.NNUE
+	s.params = e.Params
+	s.lmr = e.LMR
+}
+
+// Searchers that were not created through an engine use the default instance
+func (s *Searcher) Engine() *Engine {
+	if s.engine == nil {
+		s.SetEngine(DefaultEngine)
+	}
+	return s.engine
+}
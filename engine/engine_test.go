@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"sync"
+	"testing"
+)
+
+type engineResult struct {
+	move  Move
+	nodes int
+}
+
+func searchWithEngine(e *Engine, fen string, depth int64) engineResult {
+	s := e.MainSearcher()
+	s.Position = NewBoard()
+	s.Position.InitFEN(fen)
+	s.Info.Quiet = true
+
+	e.NewGame()
+	e.Timer.Calculate(s.Position.turn, 0, 0, 0, 0, 0, depth, 0, 0, false)
+	move := s.SearchPosition()
+	return engineResult{move, s.Info.NodesSearched}
+}
+
+func TestEnginesSearchConcurrently(t *testing.T) {
+	fens := []string{
+		"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	}
+	const depth = 7
+
+	engines := []*Engine{NewEngine(16), NewEngine(16)}
+
+	want := make([]engineResult, len(fens))
+	for i, fen := range fens {
+		want[i] = searchWithEngine(engines[i], fen, depth)
+	}
+
+	got := make([]engineResult, len(fens))
+	var wg sync.WaitGroup
+	for i, fen := range fens {
+		wg.Add(1)
+		go func(i int, fen string) {
+			defer wg.Done()
+			got[i] = searchWithEngine(engines[i], fen, depth)
+		}(i, fen)
+	}
+	wg.Wait()
+
+	for i := range fens {
+		if got[i] != want[i] {
+			t.Errorf("TestEnginesSearchConcurrently (%s): got %s with %d nodes, wanted %s with %d nodes", fens[i], got[i].move, got[i].nodes, want[i].move, want[i].nodes)
+		}
+	}
+}
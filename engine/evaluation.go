@@ -18,20 +18,25 @@ var REVERSE_SQUARE = [64]int{
 	0, 1, 2, 3, 4, 5, 6, 7,
 }
 
+// Evaluates the position with the default network
 func EvaluateNNUE(b *Board) int {
+	return GlobalNNUE.Evaluate(b)
+}
+
+func (nnue *NNUE) Evaluate(b *Board) int {
 	if b.IsInsufficientMaterial() {
 		return 0
 	}
 
-	GlobalNNUE.ApplyLazyUpdates(b)
+	nnue.ApplyLazyUpdates(b)
 
 	accs := b.accumulatorStack[b.accumulatorIdx]
 	eval := 0
 	stm := b.turn
 	if stm == WHITE {
-		eval = int(Forward(&GlobalNNUE, &accs.white, &accs.black))
+		eval = int(Forward(nnue, &accs.white, &accs.black))
 	} else {
-		eval = -int(Forward(&GlobalNNUE, &accs.black, &accs.white))
+		eval = -int(Forward(nnue, &accs.black, &accs.white))
 	}
 
 	if eval < -WIN_VAL {
@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Reasons a FEN can be rejected by ParseFEN. The returned *FENError wraps
+// one of them, so callers can tell them apart with errors.Is.
+var (
+	ErrFENFields          = errors.New("need 6 fields, or 4 without move counters")
+	ErrFENRanks           = errors.New("piece placement needs 8 ranks of 8 squares")
+	ErrFENPiece           = errors.New("unexpected character in piece placement")
+	ErrFENKings           = errors.New("each side needs exactly one king")
+	ErrFENPawnRank        = errors.New("pawn on the first or last rank")
+	ErrFENSideToMove      = errors.New("side to move must be w or b")
+	ErrFENCastling        = errors.New("castling rights do not match the kings and rooks")
+	ErrFENEnPassant       = errors.New("impossible en passant square")
+	ErrFENClock           = errors.New("move counters must be non-negative integers")
+	ErrFENOpponentInCheck = errors.New("side not to move is in check")
+)
+
+type FENError struct {
+	FEN    string
+	Err    error  // One of the ErrFEN values
+	Detail string // The offending part of the FEN, if there is one
+}
+
+func (e *FENError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("invalid fen %q: %v", e.FEN, e.Err)
+	}
+	return fmt.Sprintf("invalid fen %q: %v (%s)", e.FEN, e.Err, e.Detail)
+}
+
+func (e *FENError) Unwrap() error {
+	return e.Err
+}
+
+// Squares that must hold the king and rook for each castling right
+var FEN_CASTLING_PIECES = map[rune][2]struct {
+	piece  rune
+	square Square
+}{
+	'K': {{'K', E1}, {'R', H1}},
+	'Q': {{'K', E1}, {'R', A1}},
+	'k': {{'k', E8}, {'r', H8}},
+	'q': {{'k', E8}, {'r', A8}},
+}
+
+// Parses and validates a FEN. Besides the syntax of every field, the position
+// itself has to make sense: one king each, no pawns on the back ranks, castling
+// rights backed by a king and rook on their squares, an en passant square just
+// behind a pawn that could have made the double push, and the side that just
+// moved not left in check. The move counters may be left out.
+func ParseFEN(fen string) (*Board, error) {
+	invalid := func(err error, detail string) (*Board, error) {
+		return nil, &FENError{FEN: fen, Err: err, Detail: detail}
+	}
+
+	fields := strings.Fields(fen)
+	if len(fields) == 4 {
+		fields = append(fields, "0", "1")
+	}
+	if len(fields) != 6 {
+		return invalid(ErrFENFields, fmt.Sprintf("got %d", len(fields)))
+	}
+
+	// Piece placement, from a8 to h1
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 8 {
+		return invalid(ErrFENRanks, fmt.Sprintf("got %d ranks", len(ranks)))
+	}
+	for _, rank := range ranks {
+		file := 0
+		lastDigit := false
+		for _, c := range rank {
+			switch {
+			case c >= '1' && c <= '8' && !lastDigit:
+				file += int(c - '0')
+				lastDigit = true
+				continue
+			case strings.ContainsRune("PBNRQKpbnrqk", c):
+				file++
+			case c >= '1' && c <= '8':
+				return invalid(ErrFENRanks, fmt.Sprintf("rank %q", rank))
+			default:
+				return invalid(ErrFENPiece, fmt.Sprintf("%q", c))
+			}
+			lastDigit = false
+		}
+		if file != 8 {
+			return invalid(ErrFENRanks, fmt.Sprintf("rank %q has %d squares", rank, file))
+		}
+	}
+	if fields[1] != "w" && fields[1] != "b" {
+		return invalid(ErrFENSideToMove, fmt.Sprintf("%q", fields[1]))
+	}
+
+	if fields[2] != "-" {
+		seen := map[rune]bool{}
+		for _, right := range fields[2] {
+			if _, ok := FEN_CASTLING_PIECES[right]; !ok || seen[right] {
+				return invalid(ErrFENCastling, fmt.Sprintf("%q", fields[2]))
+			}
+			seen[right] = true
+		}
+	}
+
+	if _, ok := STRING_TO_SQUARE_MAP[fields[3]]; !ok && fields[3] != "-" {
+		return invalid(ErrFENEnPassant, fmt.Sprintf("%q", fields[3]))
+	}
+
+	for _, clock := range fields[4:] {
+		if n, err := strconv.Atoi(clock); err != nil || n < 0 {
+			return invalid(ErrFENClock, fmt.Sprintf("%q", clock))
+		}
+	}
+
+	b := NewBoard()
+	b.InitFEN(strings.Join(fields, " "))
+	if err := b.checkPosition(); err != nil {
+		err.FEN = fen
+		return nil, err
+	}
+	return b, nil
+}
+
+// Checks the position itself the way ParseFEN does, so boards set up in other
+// ways are held to the same rules. The FEN of the returned error is left to
+// the caller.
+func (b *Board) checkPosition() *FENError {
+	invalid := func(err error, detail string) *FENError {
+		return &FENError{Err: err, Detail: detail}
+	}
+
+	if PopCount(b.pieces[W_K]) != 1 || PopCount(b.pieces[B_K]) != 1 {
+		return invalid(ErrFENKings, "")
+	}
+	for sq := A1; sq <= H8; sq++ {
+		backRank := SquareToRank(sq) == 0 || SquareToRank(sq) == 7
+		if backRank && (b.squares[sq] == W_P || b.squares[sq] == B_P) {
+			return invalid(ErrFENPawnRank, SQUARE_TO_STRING_MAP[sq])
+		}
+	}
+
+	for i, right := range "KQkq" {
+		if b.castlingRights&(1<<i) == 0 {
+			continue
+		}
+		for _, p := range FEN_CASTLING_PIECES[right] {
+			if b.squares[p.square] != stringToPieceMap[string(p.piece)] {
+				return invalid(ErrFENCastling, fmt.Sprintf("%c needs %c on %s", right, p.piece, SQUARE_TO_STRING_MAP[p.square]))
+			}
+		}
+	}
+
+	// The en passant square lies behind a pawn of the side that just moved,
+	// with the square it came from empty
+	if ep := b.enPassant; ep != EMPTY_SQ {
+		rank, pawn, dir := Rank(5), B_P, -8
+		if b.turn == BLACK {
+			rank, pawn, dir = 2, W_P, 8
+		}
+		if SquareToRank(ep) != rank || b.squares[ep] != EMPTY || b.squares[int(ep)+dir] != pawn || b.squares[int(ep)-dir] != EMPTY {
+			return invalid(ErrFENEnPassant, fmt.Sprintf("%q", SQUARE_TO_STRING_MAP[ep]))
+		}
+	}
+
+	if b.IsCheck(ReverseColor(b.turn)) {
+		return invalid(ErrFENOpponentInCheck, "")
+	}
+	// IsCheck leaves out the king, which can only give check from next to the other king
+	whiteKing, blackKing := Square(BitScanForward(b.pieces[W_K])), Square(BitScanForward(b.pieces[B_K]))
+	if KingAttacks(whiteKing)&b.pieces[B_K] != 0 {
+		return invalid(ErrFENOpponentInCheck, fmt.Sprintf("kings on %s and %s", SQUARE_TO_STRING_MAP[whiteKing], SQUARE_TO_STRING_MAP[blackKing]))
+	}
+	return nil
+}
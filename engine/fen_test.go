@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseFEN(t *testing.T) {
+	InitializeEverythingExceptTTable()
+
+	valid := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+		"rnbqkbnr/pppp1ppp/8/8/3Pp3/8/PPP1PPPP/RNBQKBNR b Kq d3 0 3",
+		"8/8/4k3/8/8/3K4/8/8 b - -",
+		"  8/8/4k3/8/8/3K4/8/8   w  -  -  12  40 ",
+	}
+	for _, fen := range valid {
+		if _, err := ParseFEN(fen); err != nil {
+			t.Errorf("TestParseFEN (%s): %v", fen, err)
+		}
+	}
+
+	invalid := []struct {
+		fen  string
+		want error
+	}{
+		{"", ErrFENFields},
+		{"8/8/4k3/8/8/3K4/8/8 w - - 0", ErrFENFields},
+		{"8/8/4k3/8/8/3K4/8 w - - 0 1", ErrFENRanks},
+		{"8/8/4k3/8/8/3K4/8/7 w - - 0 1", ErrFENRanks},
+		{"8/8/4k3/8/8/3K4/8/44 w - - 0 1", ErrFENRanks},
+		{"8/8/4k3/8/8/3K4/8/ppppppppp w - - 0 1", ErrFENRanks},
+		{"8/8/4k3/8/8/3K4/8/7x w - - 0 1", ErrFENPiece},
+		{"8/8/4k3/8/8/3K4/8/9 w - - 0 1", ErrFENPiece},
+		{"8/8/8/8/8/3K4/8/8 w - - 0 1", ErrFENKings},
+		{"8/8/4k3/8/8/3K4/8/3K4 w - - 0 1", ErrFENKings},
+		{"P7/8/4k3/8/8/3K4/8/8 w - - 0 1", ErrFENPawnRank},
+		{"8/8/4k3/8/8/3K4/8/p7 w - - 0 1", ErrFENPawnRank},
+		{"8/8/4k3/8/8/3K4/8/8 x - - 0 1", ErrFENSideToMove},
+		{"4k3/8/8/8/8/8/8/4K3 w K - 0 1", ErrFENCastling},
+		{"r3k2r/8/8/8/8/8/8/R2K3R w Q - 0 1", ErrFENCastling},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KK - 0 1", ErrFENCastling},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w X - 0 1", ErrFENCastling},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e3 0 1", ErrFENEnPassant},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e6 0 1", ErrFENEnPassant},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPPPPPP/RNBQKBNR b KQkq e3 0 1", ErrFENEnPassant},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq z9 0 1", ErrFENEnPassant},
+		{"8/8/4k3/8/8/3K4/8/8 w - - -1 1", ErrFENClock},
+		{"8/8/4k3/8/8/3K4/8/8 w - - 0 one", ErrFENClock},
+		{"R3k3/8/8/8/8/8/8/4K3 w - - 0 1", ErrFENOpponentInCheck},
+		{"5Kk1/4Q3/8/8/8/8/8/8 w - - 0 1", ErrFENOpponentInCheck},
+		{"8/8/4k3/3K4/8/8/8/8 b - - 0 1", ErrFENOpponentInCheck},
+	}
+	for _, test := range invalid {
+		_, err := ParseFEN(test.fen)
+		if !errors.Is(err, test.want) {
+			t.Errorf("TestParseFEN (%q): got %v, wanted %v", test.fen, err, test.want)
+		}
+		fenErr := &FENError{}
+		if err != nil && (!errors.As(err, &fenErr) || fenErr.FEN != test.fen) {
+			t.Errorf("TestParseFEN (%q): got %T, wanted *FENError", test.fen, err)
+		}
+	}
+}
+
+func FuzzParseFEN(f *testing.F) {
+	InitializeEverythingExceptTTable()
+
+	seeds := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+		"8/8/4k3/8/8/3K4/8/8 b - -",
+		"8/8/4k3/8/8/3K4/8/9 w - - 0 1",
+		"8/8/8/8 w - - 0 1",
+		"//////// w - - 0 1",
+		"",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, fen string) {
+		b, err := ParseFEN(fen)
+		if err != nil {
+			return
+		}
+
+		// Accepted positions survive a round trip and move generation
+		again, err := ParseFEN(b.ToFEN())
+		if err != nil {
+			t.Fatalf("%q was accepted, but its round trip %q was not: %v", fen, b.ToFEN(), err)
+		}
+		if again.zobrist != b.zobrist {
+			t.Fatalf("%q changed in the round trip to %q", fen, b.ToFEN())
+		}
+		for _, mv := range b.GenerateLegalMoves() {
+			b.MakeMove(mv)
+			b.Undo()
+		}
+	})
+}
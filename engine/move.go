@@ -1,9 +1,17 @@
 package engine
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 )
 
+// Reasons LegalMoveFromUCI rejects a move
+var (
+	ErrMalformedMove = errors.New("malformed move")
+	ErrIllegalMove   = errors.New("illegal move")
+)
+
 type Move struct {
 	to         Square   // square which piece moves to
 	from       Square   // square which piece moves from
@@ -29,10 +37,38 @@ func (m Move) String() string {
 	return m.ToUCI()
 }
 
+// Reports whether uci is two squares followed by an optional promotion piece
+func IsUCIMoveSyntax(uci string) bool {
+	if len(uci) != 4 && len(uci) != 5 {
+		return false
+	}
+	_, fromOk := STRING_TO_SQUARE_MAP[uci[:2]]
+	_, toOk := STRING_TO_SQUARE_MAP[uci[2:4]]
+	return fromOk && toOk && (len(uci) == 4 || strings.ContainsRune("nbrq", rune(uci[4])))
+}
+
+// Finds the legal move of the position with the given UCI notation. The
+// error wraps ErrMalformedMove or ErrIllegalMove.
+func LegalMoveFromUCI(uci string, b *Board) (Move, error) {
+	if !IsUCIMoveSyntax(uci) {
+		return Move{}, fmt.Errorf("%w %q", ErrMalformedMove, uci)
+	}
+	for _, mv := range b.GenerateLegalMoves() {
+		if mv.ToUCI() == uci {
+			return mv, nil
+		}
+	}
+	return Move{}, fmt.Errorf("%w %q", ErrIllegalMove, uci)
+}
+
 func FromUCI(uci string, b *Board) Move {
 	// parse UCI string into Move
-	// should only be necessary for testing, as UCI gives fen position
+	// should only be necessary for testing, as UCI gives fen position.
+	// The move is not checked for legality, see LegalMoveFromUCI.
 	var m = Move{}
+	if !IsUCIMoveSyntax(uci) {
+		return m
+	}
 	var from, to Square = STRING_TO_SQUARE_MAP[uci[:2]], STRING_TO_SQUARE_MAP[uci[2:4]]
 	var promotion = EMPTY
 
@@ -128,3 +164,54 @@ func (m Move) IsNoisy() bool {
 func (m Move) IsCapture() bool {
 	return m.movetype == CAPTURE || m.movetype == CAPTURE_AND_PROMOTION || m.movetype == EN_PASSANT
 }
+
+// PackedMove is a 16-bit encoding of a move used by the transposition table:
+// bits 0-5 store the from square, bits 6-11 the to square, and bits 12-14
+// the promotion piece type (0 if the move is not a promotion). The rest of
+// the move is recovered from the board in UnpackMove.
+type PackedMove uint16
+
+func (m Move) Pack() PackedMove {
+	if m.IsEmpty() {
+		return 0
+	}
+	packed := PackedMove(m.from) | PackedMove(m.to)<<6
+	if m.movetype == PROMOTION || m.movetype == CAPTURE_AND_PROMOTION {
+		packed |= PackedMove(PieceToPieceType(m.promote)) << 12
+	}
+	return packed
+}
+
+// UnpackMove rebuilds a full move from its packed form using the current
+// board. The result mirrors the fields set by the move generator, so it can
+// be compared directly against generated moves. It is not guaranteed to be
+// legal and should be checked with IsLegal before being played.
+func (b *Board) UnpackMove(pm PackedMove) Move {
+	from := Square(pm & 0x3F)
+	to := Square((pm >> 6) & 0x3F)
+	promo := PieceType((pm >> 12) & 0x7)
+
+	piece := b.squares[from]
+	if from == to || piece == EMPTY {
+		return Move{}
+	}
+
+	m := Move{from: from, to: to, piece: piece, colorMoved: piece.GetColor()}
+
+	switch {
+	case promo != PAWN:
+		m.promote = PieceTypeToPiece(m.colorMoved, promo)
+		m.captured = b.squares[to]
+		m.movetype = ternary(m.captured == EMPTY, PROMOTION, CAPTURE_AND_PROMOTION)
+	case PieceToPieceType(piece) == KING && Abs(int(to)-int(from)) == 2:
+		m.movetype = ternary(to > from, K_CASTLE, Q_CASTLE)
+	case PieceToPieceType(piece) == PAWN && SquareToFile(from) != SquareToFile(to) && b.squares[to] == EMPTY:
+		m.movetype = EN_PASSANT
+		m.captured = ternary(m.colorMoved == WHITE, B_P, W_P)
+	default:
+		m.captured = b.squares[to]
+		m.movetype = ternary(m.captured == EMPTY, QUIET, CAPTURE)
+	}
+
+	return m
+}
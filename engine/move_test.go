@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMakeMoveFromUCI(t *testing.T) {
+	InitializeEverythingExceptTTable()
+
+	b, _ := ParseFEN("r3k2r/1P6/8/8/8/8/8/R3K1NR w KQkq - 0 1")
+	start := b.zobrist
+
+	tests := []struct {
+		move string
+		want error
+	}{
+		{"", ErrMalformedMove},
+		{"e", ErrMalformedMove},
+		{"e1g", ErrMalformedMove},
+		{"e1i1", ErrMalformedMove},
+		{"b7b8k", ErrMalformedMove},
+		{"a7b8qq", ErrMalformedMove},
+		{"0000", ErrMalformedMove},
+		{"e1g1", ErrIllegalMove},
+		{"b7b8", ErrIllegalMove},
+		{"e8d8", ErrIllegalMove},
+		{"a1a9", ErrMalformedMove},
+		{"g1g3", ErrIllegalMove},
+	}
+	for _, test := range tests {
+		if mv := FromUCI(test.move, b); errors.Is(test.want, ErrMalformedMove) && !mv.IsEmpty() {
+			t.Errorf("TestMakeMoveFromUCI (%q): FromUCI returned %v", test.move, mv)
+		}
+		if err := b.MakeMoveFromUCI(test.move); !errors.Is(err, test.want) {
+			t.Errorf("TestMakeMoveFromUCI (%q): got %v, wanted %v", test.move, err, test.want)
+		}
+		if b.zobrist != start || len(b.history) != 0 {
+			t.Fatalf("TestMakeMoveFromUCI (%q): the rejected move changed the board", test.move)
+		}
+	}
+
+	for _, move := range []string{"e1c1", "e8g8", "b7a8n"} {
+		if err := b.MakeMoveFromUCI(move); err != nil {
+			t.Errorf("TestMakeMoveFromUCI (%q): %v", move, err)
+		}
+	}
+	// Only the position, the move counters are not compared
+	if got, want := strings.Fields(b.ToFEN())[:4], "N4rk1/8/8/8/8/8/8/2KR2NR b - -"; strings.Join(got, " ") != want {
+		t.Errorf("TestMakeMoveFromUCI: got %s, wanted %s", got, want)
+	}
+}
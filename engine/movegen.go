@@ -518,6 +518,26 @@ func (b *Board) GenerateQuiets() []Move {
 }
 
 func (b *Board) IsLegal(move Move) bool {
+	if !b.IsPseudoLegal(move) {
+		return false
+	}
+
+	// Check actual legality
+	stm := b.turn
+	b.MakeMoveNoUpdate(move)
+	if !b.IsCheck(stm) {
+		b.UndoNoUpdate(move)
+		return true
+	} else {
+		b.UndoNoUpdate(move)
+		return false
+	}
+}
+
+// Reports whether the move follows the rules for its piece in this position,
+// without checking whether it leaves the own king in check. Castling is
+// checked in full, including the squares the king passes.
+func (b *Board) IsPseudoLegal(move Move) bool {
 	stm := b.turn
 	if move.IsEmpty() || move.colorMoved != stm {
 		return false
@@ -540,9 +560,37 @@ func (b *Board) IsLegal(move Move) bool {
 		return false
 	}
 
-	switch PieceToPieceType(move.piece) {
+	pieceType := PieceToPieceType(move.piece)
+
+	// Special move types can only be played by the matching piece
+	isPromotion := move.movetype == PROMOTION || move.movetype == CAPTURE_AND_PROMOTION
+	if (isPromotion || move.movetype == EN_PASSANT) && pieceType != PAWN {
+		return false
+	}
+	if (move.movetype == K_CASTLE || move.movetype == Q_CASTLE) && pieceType != KING {
+		return false
+	}
+
+	toBB := SQUARE_TO_BITBOARD[move.to]
+
+	switch pieceType {
 	case PAWN:
 		pawnDir := ternary(stm == WHITE, NORTH, SOUTH)
+
+		// Ensure the destination is reachable by a push or a capture
+		if move.IsCapture() {
+			if COLOR_TO_PAWN_LOOKUP[stm][move.from]&toBB == 0 {
+				return false
+			}
+		} else if move.from.GoDirection(pawnDir) != move.to && move.from.GoDirection(2*pawnDir) != move.to {
+			return false
+		}
+
+		// Pawns reaching the last rank must promote
+		if !isPromotion && toBB&(RANKS[R1]|RANKS[R8]) != 0 {
+			return false
+		}
+
 		// Ensure pawn double push is valid
 		if move.from.GoDirection(2*pawnDir) == move.to {
 			if b.squares[move.from.GoDirection(pawnDir)] != EMPTY {
@@ -574,19 +622,36 @@ func (b *Board) IsLegal(move Move) bool {
 				return false
 			}
 		}
+	case KNIGHT:
+		if KnightAttacks(move.from)&toBB == 0 {
+			return false
+		}
 	case ROOK:
-		if SQUARES_BETWEEN[move.from][move.to]&b.occupied != 0 {
+		if RookAttacks(move.from, b.occupied)&toBB == 0 {
 			return false
 		}
 	case BISHOP:
-		if SQUARES_BETWEEN[move.from][move.to]&b.occupied != 0 {
+		if BishopAttacks(move.from, b.occupied)&toBB == 0 {
 			return false
 		}
 	case QUEEN:
-		if SQUARES_BETWEEN[move.from][move.to]&b.occupied != 0 {
+		if (RookAttacks(move.from, b.occupied)|BishopAttacks(move.from, b.occupied))&toBB == 0 {
 			return false
 		}
 	case KING:
+		if move.movetype != K_CASTLE && move.movetype != Q_CASTLE && KingAttacks(move.from)&toBB == 0 {
+			return false
+		}
+
+		if move.movetype == K_CASTLE || move.movetype == Q_CASTLE {
+			if move.from != ternary(stm == WHITE, E1, E8) {
+				return false
+			}
+			if move.to != ternary(move.movetype == K_CASTLE, ternary(stm == WHITE, G1, G8), ternary(stm == WHITE, C1, C8)) {
+				return false
+			}
+		}
+
 		if move.movetype == K_CASTLE {
 			if b.IsCheck(stm) {
 				return false
@@ -640,15 +705,116 @@ func (b *Board) IsLegal(move Move) bool {
 		}
 	}
 
-	// Check actual legality
-	b.MakeMoveNoUpdate(move)
-	if !b.IsCheck(stm) {
-		b.UndoNoUpdate(move)
+	return true
+}
+
+// PSEUDO-LEGAL MOVE GENERATION
+//
+//	The pseudo-legal generators skip the pin and attack maps GenerateMoves builds: pinned pieces
+//	move freely, the king may step onto attacked squares and en passant is not tested for
+//	exposing the king. Castling is still checked in full, and in check the other pieces are
+//	limited to taking or blocking the checker. IsLegalPseudoMove then decides whether a
+//	generated move is legal. The move picker only asks that for the moves it returns, so moves
+//	behind a beta cutoff are never checked, and every stage only generates its own moves.
+
+// Captures, including en passant and captures that promote
+func (b *Board) GeneratePseudoCaptures() []Move {
+	return b.generatePseudoMoves(false, false)
+}
+
+// Captures and promotions
+func (b *Board) GeneratePseudoNoisies() []Move {
+	return b.generatePseudoMoves(false, true)
+}
+
+// Moves to empty squares that do not promote, including castling
+func (b *Board) GeneratePseudoQuiets() []Move {
+	return b.generatePseudoMoves(true, false)
+}
+
+func (b *Board) generatePseudoMoves(quiets bool, promotions bool) []Move {
+	m := make([]Move, 0, 40)
+
+	player := b.turn
+	opponent := ReverseColor(player)
+	playerPieces := b.colors[player]
+	opponentPieces := b.colors[opponent]
+	playerKing := Square(BitScanForward(b.GetColorPieces(KING, player)))
+	checkers := b.OpponentAttacksOf(playerKing, b.occupied, player)
+
+	lastRank := ternary(player == WHITE, RANKS[R8], RANKS[R1])
+	allowed := ternary(quiets, b.empty, opponentPieces)
+	pawnAllowed := ternary(quiets, b.empty&^lastRank, opponentPieces|ternary(promotions, lastRank&b.empty, 0))
+
+	b.KingMoves(&m, playerKing, ^allowed, playerPieces, player)
+
+	numCheckers := PopCount(checkers)
+	if numCheckers >= 2 {
+		return m // Only king moves in double check
+	}
+	if numCheckers == 1 {
+		evasions := SQUARES_BETWEEN[playerKing][BitScanForward(checkers)] | checkers
+		allowed &= evasions
+		pawnAllowed &= evasions
+	}
+
+	// No pieces count as pinned
+	b.KnightMoves(&m, b.GetColorPieces(KNIGHT, player), 0, playerPieces, player, allowed)
+	b.PawnMoves(&m, b.GetColorPieces(PAWN, player), 0, b.occupied, opponentPieces, player, pawnAllowed)
+	b.BishopMoves(&m, b.GetColorPieces(BISHOP, player)|b.GetColorPieces(QUEEN, player), 0, playerPieces, opponentPieces, player, allowed)
+	b.RookMoves(&m, b.GetColorPieces(ROOK, player)|b.GetColorPieces(QUEEN, player), 0, playerPieces, opponentPieces, player, allowed)
+
+	if !quiets && b.enPassant != EMPTY_SQ {
+		pawns := COLOR_TO_PAWN_LOOKUP[opponent][b.enPassant] & b.GetColorPieces(PAWN, player)
+		for pawns != 0 {
+			sq := Square(PopLSB(&pawns))
+			m = append(m, Move{from: sq, to: b.enPassant, movetype: EN_PASSANT, captured: ternary(player == WHITE, B_P, W_P), colorMoved: player, piece: b.squares[sq]})
+		}
+	}
+
+	// Castling needs the squares the king passes to be safe, so only those are looked at
+	if quiets && numCheckers == 0 && ternary(player == WHITE, b.OO || b.OOO, b.oo || b.ooo) {
+		attacks := u64(0)
+		for _, sq := range ternary(player == WHITE, []Square{C1, D1, F1, G1}, []Square{C8, D8, F8, G8}) {
+			if b.OpponentAttacksOf(sq, b.occupied, player) != 0 {
+				attacks |= SQUARE_TO_BITBOARD[sq]
+			}
+		}
+		b.CastlingMoves(&m, playerKing, attacks, player)
+	}
+
+	return m
+}
+
+// Reports whether a move of the pseudo-legal generators, or one that passed
+// IsPseudoLegal, is legal. pinned and checkers are those of the side to move,
+// as returned by PinnedPieces.
+func (b *Board) IsLegalPseudoMove(move Move, pinned u64, checkers u64) bool {
+	stm := b.turn
+	king := Square(BitScanForward(b.GetColorPieces(KING, stm)))
+
+	switch {
+	case move.movetype == K_CASTLE || move.movetype == Q_CASTLE:
 		return true
-	} else {
+	case move.from == king:
+		// Without the king, so it cannot hide from a slider behind itself
+		return b.OpponentAttacksOf(move.to, b.occupied&^SQUARE_TO_BITBOARD[king], stm) == 0
+	case move.movetype == EN_PASSANT:
+		// Taking the pawn can uncover the king along the rank, so play it out
+		b.MakeMoveNoUpdate(move)
+		legal := !b.IsCheck(stm)
 		b.UndoNoUpdate(move)
-		return false
+		return legal
+	}
+
+	// In check the move has to take or block the checker, and pinned
+	// pieces may only move along the pin
+	if checkers != 0 {
+		if PopCount(checkers) > 1 || (SQUARES_BETWEEN[king][BitScanForward(checkers)]|checkers)&SQUARE_TO_BITBOARD[move.to] == 0 {
+			return false
+		}
 	}
+	return pinned&SQUARE_TO_BITBOARD[move.from] == 0 || LINE[king][move.from]&SQUARE_TO_BITBOARD[move.to] != 0
 }
 
 func (b *Board) OpponentAttacksOf(sq Square, occ u64, stm Color) u64 {
@@ -0,0 +1,523 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// REFERENCE MOVE GENERATOR
+//
+//	A slow mailbox move generator that only looks at the squares array: pieces walk the board
+//	square by square, and a move is legal if the king is not attacked after playing it on a copy
+//	of the squares. It shares nothing with the bitboard generator besides the Move type, so the
+//	two can be compared on random games to catch pin, check and en passant bugs perft misses.
+
+type refPosition struct {
+	squares   [64]Piece
+	turn      Color
+	castling  [2][2]bool // [color][kingside, queenside]
+	enPassant Square
+}
+
+var (
+	REF_KNIGHT_STEPS = [][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	REF_KING_STEPS   = [][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	REF_ROOK_RAYS    = [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	REF_BISHOP_RAYS  = [][2]int{{1, 1}, {-1, 1}, {-1, -1}, {1, -1}}
+)
+
+func newRefPosition(b *Board) refPosition {
+	return refPosition{
+		squares:   b.squares,
+		turn:      b.turn,
+		castling:  [2][2]bool{{b.OO, b.OOO}, {b.oo, b.ooo}},
+		enPassant: b.enPassant,
+	}
+}
+
+// Returns the square file and rank steps away, or false if that is off the board
+func refStep(sq int, file int, rank int) (int, bool) {
+	f, r := sq%8+file, sq/8+rank
+	if f < 0 || f > 7 || r < 0 || r > 7 {
+		return 0, false
+	}
+	return r*8 + f, true
+}
+
+func refColor(p Piece) Color {
+	if p == EMPTY {
+		return NONE
+	}
+	return ternary(p < B_P, WHITE, BLACK)
+}
+
+func refType(p Piece) PieceType {
+	return PieceType(int(p) % 6)
+}
+
+func (p *refPosition) isAttacked(sq int, by Color) bool {
+	is := func(target int, ok bool, pt PieceType) bool {
+		return ok && p.squares[target] != EMPTY && refColor(p.squares[target]) == by && refType(p.squares[target]) == pt
+	}
+
+	// A pawn of by attacks sq from one rank behind it, as seen from by
+	back := ternary(by == WHITE, -1, 1)
+	if target, ok := refStep(sq, -1, back); is(target, ok, PAWN) {
+		return true
+	}
+	if target, ok := refStep(sq, 1, back); is(target, ok, PAWN) {
+		return true
+	}
+	for _, step := range REF_KNIGHT_STEPS {
+		if target, ok := refStep(sq, step[0], step[1]); is(target, ok, KNIGHT) {
+			return true
+		}
+	}
+	for _, step := range REF_KING_STEPS {
+		if target, ok := refStep(sq, step[0], step[1]); is(target, ok, KING) {
+			return true
+		}
+	}
+
+	slides := func(rays [][2]int, pt PieceType) bool {
+		for _, ray := range rays {
+			for target, ok := refStep(sq, ray[0], ray[1]); ok; target, ok = refStep(target, ray[0], ray[1]) {
+				if p.squares[target] == EMPTY {
+					continue
+				}
+				if is(target, true, pt) || is(target, true, QUEEN) {
+					return true
+				}
+				break
+			}
+		}
+		return false
+	}
+	return slides(REF_ROOK_RAYS, ROOK) || slides(REF_BISHOP_RAYS, BISHOP)
+}
+
+func (p *refPosition) isCheck(c Color) bool {
+	for sq, piece := range p.squares {
+		if piece != EMPTY && refColor(piece) == c && refType(piece) == KING {
+			return p.isAttacked(sq, ReverseColor(c))
+		}
+	}
+	return false
+}
+
+// Moves that follow the piece rules but may leave the own king in check
+func (p *refPosition) pseudoLegalMoves() []Move {
+	us, them := p.turn, ReverseColor(p.turn)
+	moves := []Move{}
+
+	add := func(from int, to int) {
+		piece, captured := p.squares[from], p.squares[to]
+		mv := Move{from: Square(from), to: Square(to), piece: piece, captured: captured, colorMoved: us, movetype: QUIET}
+		if captured != EMPTY {
+			mv.movetype = CAPTURE
+		}
+		if refType(piece) == PAWN && (to/8 == 7 || to/8 == 0) {
+			mv.movetype = ternary(captured == EMPTY, PROMOTION, CAPTURE_AND_PROMOTION)
+			for _, pt := range []PieceType{KNIGHT, BISHOP, ROOK, QUEEN} {
+				mv.promote = PieceTypeToPiece(us, pt)
+				moves = append(moves, mv)
+			}
+			return
+		}
+		moves = append(moves, mv)
+	}
+
+	for from, piece := range p.squares {
+		if piece == EMPTY || refColor(piece) != us {
+			continue
+		}
+
+		switch refType(piece) {
+		case PAWN:
+			forward := ternary(us == WHITE, 1, -1)
+			if to, ok := refStep(from, 0, forward); ok && p.squares[to] == EMPTY {
+				add(from, to)
+				startRank := ternary(us == WHITE, 1, 6)
+				if to2, ok := refStep(to, 0, forward); ok && from/8 == startRank && p.squares[to2] == EMPTY {
+					add(from, to2)
+				}
+			}
+			for _, side := range []int{-1, 1} {
+				to, ok := refStep(from, side, forward)
+				if !ok {
+					continue
+				}
+				if p.squares[to] != EMPTY && refColor(p.squares[to]) == them {
+					add(from, to)
+				} else if Square(to) == p.enPassant && p.squares[to] == EMPTY {
+					behind, _ := refStep(to, 0, -forward)
+					if p.squares[behind] == PieceTypeToPiece(them, PAWN) {
+						moves = append(moves, Move{from: Square(from), to: Square(to), piece: piece, captured: p.squares[behind], colorMoved: us, movetype: EN_PASSANT})
+					}
+				}
+			}
+		case KNIGHT, KING:
+			steps := ternary(refType(piece) == KNIGHT, REF_KNIGHT_STEPS, REF_KING_STEPS)
+			for _, step := range steps {
+				if to, ok := refStep(from, step[0], step[1]); ok && refColor(p.squares[to]) != us {
+					add(from, to)
+				}
+			}
+		default:
+			rays := map[PieceType][][2]int{
+				BISHOP: REF_BISHOP_RAYS,
+				ROOK:   REF_ROOK_RAYS,
+				QUEEN:  append(slices.Clone(REF_ROOK_RAYS), REF_BISHOP_RAYS...),
+			}[refType(piece)]
+			for _, ray := range rays {
+				for to, ok := refStep(from, ray[0], ray[1]); ok; to, ok = refStep(to, ray[0], ray[1]) {
+					if refColor(p.squares[to]) == us {
+						break
+					}
+					add(from, to)
+					if p.squares[to] != EMPTY {
+						break
+					}
+				}
+			}
+		}
+	}
+
+	// Castling: the king may not be in check, pass through or land on an attacked square
+	king, rook := PieceTypeToPiece(us, KING), PieceTypeToPiece(us, ROOK)
+	home := ternary(us == WHITE, 0, 56)
+	if p.squares[home+4] == king && !p.isAttacked(home+4, them) {
+		if p.castling[us][0] && p.squares[home+7] == rook &&
+			p.squares[home+5] == EMPTY && p.squares[home+6] == EMPTY &&
+			!p.isAttacked(home+5, them) && !p.isAttacked(home+6, them) {
+			moves = append(moves, Move{from: Square(home + 4), to: Square(home + 6), piece: king, colorMoved: us, movetype: K_CASTLE})
+		}
+		if p.castling[us][1] && p.squares[home] == rook &&
+			p.squares[home+1] == EMPTY && p.squares[home+2] == EMPTY && p.squares[home+3] == EMPTY &&
+			!p.isAttacked(home+3, them) && !p.isAttacked(home+2, them) {
+			moves = append(moves, Move{from: Square(home + 4), to: Square(home + 2), piece: king, colorMoved: us, movetype: Q_CASTLE})
+		}
+	}
+	return moves
+}
+
+// Plays the move on a copy of the squares, which is all the legality test needs
+func (p refPosition) play(mv Move) refPosition {
+	isPromotion := mv.movetype == PROMOTION || mv.movetype == CAPTURE_AND_PROMOTION
+	p.squares[mv.to] = ternary(isPromotion, mv.promote, p.squares[mv.from])
+	p.squares[mv.from] = EMPTY
+	switch mv.movetype {
+	case EN_PASSANT:
+		p.squares[int(mv.to)+ternary(mv.colorMoved == WHITE, -8, 8)] = EMPTY
+	case K_CASTLE:
+		p.squares[mv.to-1], p.squares[mv.to+1] = p.squares[mv.to+1], EMPTY
+	case Q_CASTLE:
+		p.squares[mv.to+1], p.squares[mv.to-2] = p.squares[mv.to-2], EMPTY
+	}
+	p.turn = ReverseColor(p.turn)
+	return p
+}
+
+func (p *refPosition) legalMoves() []Move {
+	legal := []Move{}
+	for _, mv := range p.pseudoLegalMoves() {
+		if after := p.play(mv); !after.isCheck(mv.colorMoved) {
+			legal = append(legal, mv)
+		}
+	}
+	return legal
+}
+
+// Identifies a move by the fields the engine relies on. The captured and
+// promotion pieces only mean something for captures and promotions.
+func refMoveKey(mv Move) string {
+	captured := ternary(mv.IsCapture(), mv.captured, EMPTY)
+	promote := ternary(mv.movetype == PROMOTION || mv.movetype == CAPTURE_AND_PROMOTION, mv.promote, EMPTY)
+	return fmt.Sprintf("%v/%d/%d/%d/%d/%d", mv, mv.movetype, mv.piece, captured, mv.colorMoved, promote)
+}
+
+func refMoveKeys(moves []Move) []string {
+	keys := make([]string, len(moves))
+	for i, mv := range moves {
+		keys[i] = refMoveKey(mv)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Returns the first difference between the engine and the reference in the
+// current position, or "" if they agree. The pseudo-legal generators have to
+// give the same moves once IsLegalPseudoMove has filtered them. candidates are extra moves to pass
+// to IsLegal, such as moves of earlier positions like the search tries.
+func compareWithReference(b *Board, candidates []Move, rng *rand.Rand) string {
+	ref := newRefPosition(b)
+	legal := ref.legalMoves()
+	captures, quiets := []Move{}, []Move{}
+	for _, mv := range legal {
+		if mv.IsCapture() {
+			captures = append(captures, mv)
+		} else {
+			quiets = append(quiets, mv)
+		}
+	}
+
+	diff := func(name string, got []Move, want []Move) string {
+		gotKeys, wantKeys := refMoveKeys(got), refMoveKeys(want)
+		if !slices.Equal(gotKeys, wantKeys) {
+			return fmt.Sprintf("%s: got %v, wanted %v", name, got, want)
+		}
+		return ""
+	}
+	if d := diff("GenerateLegalMoves", b.GenerateLegalMoves(), legal); d != "" {
+		return d
+	}
+	if d := diff("GenerateCaptures", b.GenerateCaptures(), captures); d != "" {
+		return d
+	}
+
+	// Quiets also returns en passant captures, which the move picker already has from the
+	// captures and skips, so only the moves that are not captures are compared
+	gotQuiets := slices.DeleteFunc(b.GenerateQuiets(), func(mv Move) bool { return mv.movetype == EN_PASSANT })
+	if d := diff("GenerateQuiets", gotQuiets, quiets); d != "" {
+		return d
+	}
+
+	// The pseudo-legal generators, keeping the moves IsLegalPseudoMove accepts
+	king := Square(BitScanForward(b.GetColorPieces(KING, b.turn)))
+	pinned, checkers := b.PinnedPieces(king, b.turn)
+	keepLegal := func(moves []Move) []Move {
+		return slices.DeleteFunc(moves, func(mv Move) bool { return !b.IsLegalPseudoMove(mv, pinned, checkers) })
+	}
+	noisies := slices.DeleteFunc(slices.Clone(legal), func(mv Move) bool { return !mv.IsNoisy() })
+	quietsOnly := slices.DeleteFunc(slices.Clone(quiets), func(mv Move) bool { return mv.movetype == PROMOTION })
+	if d := diff("GeneratePseudoCaptures", keepLegal(b.GeneratePseudoCaptures()), captures); d != "" {
+		return d
+	}
+	if d := diff("GeneratePseudoNoisies", keepLegal(b.GeneratePseudoNoisies()), noisies); d != "" {
+		return d
+	}
+	if d := diff("GeneratePseudoQuiets", keepLegal(b.GeneratePseudoQuiets()), quietsOnly); d != "" {
+		return d
+	}
+
+	for _, c := range []Color{WHITE, BLACK} {
+		if got, want := b.IsCheck(c), ref.isCheck(c); got != want {
+			return fmt.Sprintf("IsCheck(%d): got %t, wanted %t", c, got, want)
+		}
+	}
+
+	// Every pseudo-legal move, moves of earlier positions and random packed
+	// moves as they come out of the transposition table
+	legalKeys := refMoveKeys(legal)
+	tries := append(ref.pseudoLegalMoves(), candidates...)
+	for i := 0; i < 16; i++ {
+		promo := PackedMove(rng.Intn(int(QUEEN) + 1))
+		tries = append(tries, b.UnpackMove(PackedMove(rng.Intn(1<<12))|promo<<12))
+	}
+	for _, mv := range tries {
+		want := false
+		if !mv.IsEmpty() {
+			_, want = slices.BinarySearch(legalKeys, refMoveKey(mv))
+		}
+		if got := b.IsLegal(mv); got != want {
+			return fmt.Sprintf("IsLegal(%v, %s): got %t, wanted %t", mv, refMoveKey(mv), got, want)
+		}
+		if got := b.IsPseudoLegal(mv) && b.IsLegalPseudoMove(mv, pinned, checkers); got != want {
+			return fmt.Sprintf("IsPseudoLegal and IsLegalPseudoMove(%v, %s): got %t, wanted %t", mv, refMoveKey(mv), got, want)
+		}
+	}
+	return ""
+}
+
+// Plays a random game of up to plies moves from fen and compares every
+// position with the reference. On a difference it returns the shortest
+// FEN and move list that still shows it.
+func playAgainstReference(fen string, plies int, rng *rand.Rand) (string, string, []string) {
+	b, err := NewPosition(fen)
+	if err != nil {
+		panic(err)
+	}
+
+	fens, moves := []string{fen}, []string{}
+	candidates := []Move{}
+	for ply := 0; ; ply++ {
+		check := func(b *Board) string {
+			return compareWithReference(b, candidates, rand.New(rand.NewSource(int64(ply))))
+		}
+		if d := check(b); d != "" {
+			fen, moves := minimiseReferenceDiff(fens, moves, check)
+			return d, fen, moves
+		}
+
+		legal := b.GenerateLegalMoves()
+		if ply == plies || len(legal) == 0 {
+			return "", "", nil
+		}
+		mv := legal[rng.Intn(len(legal))]
+		candidates = append(candidates, legal...)
+		candidates = candidates[max(0, len(candidates)-64):]
+
+		b.MakeMove(mv)
+		fens, moves = append(fens, b.ToFEN()), append(moves, mv.ToUCI())
+	}
+}
+
+// Finds the latest position of the game that, with the moves after it,
+// still fails the check. fens[i] is the position after i moves.
+func minimiseReferenceDiff(fens []string, moves []string, check func(b *Board) string) (string, []string) {
+	for i := len(moves); i > 0; i-- {
+		b, err := NewPosition(fens[i])
+		if err != nil {
+			continue
+		}
+		if err := playUCIMoves(b, moves[i:]); err == nil && check(b) != "" {
+			return fens[i], moves[i:]
+		}
+	}
+	return fens[0], moves
+}
+
+func playUCIMoves(b *Board, moves []string) error {
+	for _, mv := range moves {
+		if err := b.MakeMoveFromUCI(mv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Builds a random legal position, with castling rights and an en passant
+// square where the pieces allow them
+func randomPosition(rng *rand.Rand) string {
+	for {
+		squares := [64]Piece{}
+		for i := range squares {
+			squares[i] = EMPTY
+		}
+		place := func(p Piece) {
+			for {
+				sq := rng.Intn(64)
+				if squares[sq] == EMPTY && (refType(p) != PAWN || (sq >= 8 && sq < 56)) {
+					squares[sq] = p
+					return
+				}
+			}
+		}
+
+		// Kings and rooks on their home squares now and then, for castling
+		for _, home := range []int{0, 56} {
+			if rng.Intn(2) == 0 {
+				c := ternary(home == 0, WHITE, BLACK)
+				squares[home+4] = PieceTypeToPiece(c, KING)
+				squares[home] = PieceTypeToPiece(c, ROOK)
+				squares[home+7] = PieceTypeToPiece(c, ROOK)
+			}
+		}
+		for _, king := range []Piece{W_K, B_K} {
+			if !slices.Contains(squares[:], king) {
+				place(king)
+			}
+		}
+		pieces := []Piece{W_P, W_N, W_B, W_R, W_Q, B_P, B_N, B_B, B_R, B_Q}
+		for i := rng.Intn(24); i > 0; i-- {
+			place(pieces[rng.Intn(len(pieces))])
+		}
+
+		turn := Color(rng.Intn(2))
+		castling := ""
+		for i, flag := range []string{"K", "Q", "k", "q"} {
+			home, rook := ternary(i < 2, 0, 56), ternary(i%2 == 0, 7, 0)
+			c := ternary(i < 2, WHITE, BLACK)
+			if squares[home+4] == PieceTypeToPiece(c, KING) && squares[home+rook] == PieceTypeToPiece(c, ROOK) && rng.Intn(4) != 0 {
+				castling += flag
+			}
+		}
+
+		// A pawn of the side not to move that could have just pushed two squares
+		ep := "-"
+		pawnRank, pushed := ternary(turn == WHITE, 4, 3), ternary(turn == WHITE, B_P, W_P)
+		sq, behind := pawnRank*8+rng.Intn(8), ternary(turn == WHITE, 8, -8)
+		if squares[sq] == pushed && squares[sq+behind] == EMPTY && squares[sq+2*behind] == EMPTY {
+			ep = SQUARE_TO_STRING_MAP[Square(sq+behind)]
+		}
+
+		var sb strings.Builder
+		for rank := 7; rank >= 0; rank-- {
+			empty := 0
+			for file := 0; file < 8; file++ {
+				p := squares[rank*8+file]
+				if p == EMPTY {
+					empty++
+					continue
+				}
+				if empty > 0 {
+					fmt.Fprint(&sb, empty)
+					empty = 0
+				}
+				sb.WriteString(p.ToString())
+			}
+			if empty > 0 {
+				fmt.Fprint(&sb, empty)
+			}
+			if rank > 0 {
+				sb.WriteString("/")
+			}
+		}
+		fen := fmt.Sprintf("%s %s %s %s 0 1", sb.String(), ternary(turn == WHITE, "w", "b"), ternary(castling == "", "-", castling), ep)
+		if _, err := ParseFEN(fen); err == nil {
+			return fen
+		}
+	}
+}
+
+var REFERENCE_TEST_FENS = []string{
+	"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+	"8/8/1k6/2b5/2pP4/8/5K2/8 b - d3 0 1",
+	"rnbq1bnr/ppp1pppp/8/8/k2p3R/8/PPPPPPPP/RNBQKBN1 w - - 0 1",
+	"2K2r2/4P3/8/8/8/8/8/3k4 w - - 0 1",
+}
+
+func TestMoveGenMatchesReference(t *testing.T) {
+	InitializeEverythingExceptTTable()
+	rng := rand.New(rand.NewSource(1))
+
+	fens := slices.Clone(REFERENCE_TEST_FENS)
+	for i := 0; i < 100; i++ {
+		fens = append(fens, randomPosition(rng))
+	}
+	for _, fen := range fens {
+		for game := 0; game < 2; game++ {
+			if d, fen, moves := playAgainstReference(fen, 80, rng); d != "" {
+				t.Fatalf("TestMoveGenMatchesReference: %s\nfen: %s\nmoves: %s", d, fen, strings.Join(moves, " "))
+			}
+		}
+	}
+}
+
+// Fuzzes the starting position and the random game played from it. Inputs
+// that are not a valid FEN pick a random position from the seed instead.
+func FuzzMoveGen(f *testing.F) {
+	InitializeEverythingExceptTTable()
+
+	for i, fen := range REFERENCE_TEST_FENS {
+		f.Add(fen, int64(i))
+	}
+	f.Add("", int64(0))
+
+	f.Fuzz(func(t *testing.T, fen string, seed int64) {
+		rng := rand.New(rand.NewSource(seed))
+		if _, err := ParseFEN(fen); err != nil {
+			fen = randomPosition(rng)
+		}
+		if d, fen, moves := playAgainstReference(fen, 60, rng); d != "" {
+			t.Fatalf("%s\nfen: %s\nmoves: %s", d, fen, strings.Join(moves, " "))
+		}
+	})
+}
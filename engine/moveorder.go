@@ -49,26 +49,32 @@ type MovePicker struct {
 	ply         int
 	currIdx     int
 	lastStage   Stage
+	moveStage   Stage // Stage the last move returned came from
 	QS          bool
 	skipQuiets  bool
+	pseudoLegal bool // Generates pseudo-legal moves and checks them when returned, see movegen.go
+	pinsKnown   bool // Whether pinned and checkers are set yet
+	pinned      u64
+	checkers    u64
 }
 
 func NewMovePicker(s *Searcher, ss []SearchStack, ttMove Move, killer1 Move, killer2 Move, counter Move, ply int, fromQS bool) *MovePicker {
 	mp := &MovePicker{
-		board:      s.Position,
-		ttMove:     ttMove,
-		stage:      ternary(ttMove.IsEmpty(), TT_MOVE+1, TT_MOVE),
-		killer1:    killer1,
-		killer2:    killer2,
-		counter:    counter,
-		history:    &s.History,
-		searcher:   s,
-		stack:      ss,
-		ply:        ply,
-		currIdx:    0,
-		lastStage:  ternary(fromQS, GOOD_CAPTURES, BAD_CAPTURES),
-		QS:         fromQS,
-		skipQuiets: fromQS,
+		board:       s.Position,
+		ttMove:      ttMove,
+		stage:       ternary(ttMove.IsEmpty(), TT_MOVE+1, TT_MOVE),
+		killer1:     killer1,
+		killer2:     killer2,
+		counter:     counter,
+		history:     &s.History,
+		searcher:    s,
+		stack:       ss,
+		ply:         ply,
+		currIdx:     0,
+		lastStage:   ternary(fromQS, GOOD_CAPTURES, BAD_CAPTURES),
+		QS:          fromQS,
+		skipQuiets:  fromQS,
+		pseudoLegal: s.engine != nil && s.engine.PseudoLegal,
 	}
 	return mp
 }
@@ -123,20 +129,48 @@ func (mp *MovePicker) SkipQuiets() {
 	mp.skipQuiets = true
 }
 
+// Checks a TT, killer or counter move, which may come from another position
+func (mp *MovePicker) isValid(move Move) bool {
+	if !mp.pseudoLegal {
+		return mp.board.IsLegal(move)
+	}
+	return mp.board.IsPseudoLegal(move) && mp.isLegal(move)
+}
+
+// Checks a generated move, which is always legal unless generated pseudo-legally.
+// The pins are only looked up once a move of this node has to be checked.
+func (mp *MovePicker) isLegal(move Move) bool {
+	if !mp.pseudoLegal {
+		return true
+	}
+	if !mp.pinsKnown {
+		king := Square(BitScanForward(mp.board.GetColorPieces(KING, mp.board.turn)))
+		mp.pinned, mp.checkers = mp.board.PinnedPieces(king, mp.board.turn)
+		mp.pinsKnown = true
+	}
+	return mp.board.IsLegalPseudoMove(move, mp.pinned, mp.checkers)
+}
+
 func (mp *MovePicker) NextMove() Move {
 	for mp.stage <= mp.lastStage {
+		mp.moveStage = mp.stage
 		switch mp.stage {
 		case TT_MOVE:
 			mp.stage++
-			if mp.board.IsLegal(mp.ttMove) {
+			if mp.isValid(mp.ttMove) {
 				return mp.ttMove
 			}
 		case GEN_CAPTURES:
 			mp.stage++
-			if mp.QS {
+			switch {
+			case mp.pseudoLegal && mp.QS:
+				mp.processMoves(mp.board.GeneratePseudoCaptures())
+			case mp.pseudoLegal:
+				mp.processMoves(mp.board.GeneratePseudoNoisies())
+			case mp.QS:
 				moves := mp.board.GenerateCaptures()
 				mp.processMoves(moves)
-			} else {
+			default:
 				moves := mp.board.GenerateNoisies()
 				mp.processMoves(moves)
 			}
@@ -149,7 +183,7 @@ func (mp *MovePicker) NextMove() Move {
 					mp.currIdx++
 					continue
 				}
-				if move == mp.ttMove {
+				if move == mp.ttMove || !mp.isLegal(move) {
 					mp.currIdx++
 					continue
 				}
@@ -163,6 +197,9 @@ func (mp *MovePicker) NextMove() Move {
 			if mp.getNextAndSwap(mp.promotions, mp.currIdx) {
 				move := mp.promotions[mp.currIdx].move
 				mp.currIdx++
+				if !mp.isLegal(move) {
+					continue
+				}
 				return move
 			}
 			mp.currIdx = 0
@@ -175,7 +212,7 @@ func (mp *MovePicker) NextMove() Move {
 			}
 
 			if mp.killer1 != mp.ttMove {
-				if mp.board.IsLegal(mp.killer1) {
+				if mp.isValid(mp.killer1) {
 					return mp.killer1
 				}
 			}
@@ -187,7 +224,7 @@ func (mp *MovePicker) NextMove() Move {
 			}
 
 			if mp.killer2 != mp.killer1 && mp.killer2 != mp.ttMove {
-				if mp.board.IsLegal(mp.killer2) {
+				if mp.isValid(mp.killer2) {
 					return mp.killer2
 				}
 			}
@@ -199,7 +236,7 @@ func (mp *MovePicker) NextMove() Move {
 			}
 
 			if mp.counter != mp.killer1 && mp.counter != mp.killer2 && mp.counter != mp.ttMove {
-				if mp.board.IsLegal(mp.counter) {
+				if mp.isValid(mp.counter) {
 					return mp.counter
 				}
 			}
@@ -209,7 +246,11 @@ func (mp *MovePicker) NextMove() Move {
 			if mp.skipQuiets {
 				continue
 			}
-			mp.processMoves(mp.board.GenerateQuiets())
+			if mp.pseudoLegal {
+				mp.processMoves(mp.board.GeneratePseudoQuiets())
+			} else {
+				mp.processMoves(mp.board.GenerateQuiets())
+			}
 
 		case HISTORY_QUIETS:
 			if mp.skipQuiets {
@@ -220,7 +261,7 @@ func (mp *MovePicker) NextMove() Move {
 
 			if mp.getNextAndSwap(mp.quiets, mp.currIdx) {
 				move := mp.quiets[mp.currIdx].move
-				if move == mp.killer1 || move == mp.killer2 || move == mp.ttMove {
+				if move == mp.killer1 || move == mp.killer2 || move == mp.ttMove || !mp.isLegal(move) {
 					mp.currIdx++
 					continue
 				}
@@ -235,7 +276,7 @@ func (mp *MovePicker) NextMove() Move {
 		case BAD_CAPTURES:
 			if mp.getNextAndSwap(mp.badCaptures, mp.currIdx) {
 				move := mp.badCaptures[mp.currIdx].move
-				if move == mp.ttMove {
+				if move == mp.ttMove || !mp.isLegal(move) {
 					mp.currIdx++
 					continue
 				}
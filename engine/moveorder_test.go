@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// Picks every move of the position, returning them as sorted UCI strings
+func pickAll(s *Searcher, ttMove Move, killer1 Move, killer2 Move, counter Move, fromQS bool) []string {
+	ss := make([]SearchStack, MAX_PLY)
+	mp := NewMovePicker(s, ss, ttMove, killer1, killer2, counter, 0, fromQS)
+	picked := []string{}
+	for move := mp.NextMove(); !move.IsEmpty(); move = mp.NextMove() {
+		picked = append(picked, move.ToUCI())
+	}
+	slices.Sort(picked)
+	// The counter move comes once from its own stage and again with the quiets
+	return slices.Compact(picked)
+}
+
+func TestMovePickerModesAgree(t *testing.T) {
+	InitializeEverythingExceptTTable()
+	rng := rand.New(rand.NewSource(1))
+
+	legal, pseudo := NewEngine(1), NewEngine(1)
+	pseudo.PseudoLegal = true
+
+	fens := slices.Clone(REFERENCE_TEST_FENS)
+	for i := 0; i < 40; i++ {
+		fens = append(fens, randomPosition(rng))
+	}
+	for _, fen := range fens {
+		b := mustPosition(t, fen)
+		earlier := []Move{{}}
+		for ply := 0; ply < 60; ply++ {
+			moves := b.GenerateLegalMoves()
+			if len(moves) == 0 {
+				break
+			}
+
+			// TT, killer and counter moves from earlier positions, which may not fit this one
+			pick := func() Move { return earlier[rng.Intn(len(earlier))] }
+			ttMove, killer1, killer2, counter := pick(), pick(), pick(), pick()
+
+			want := []string{}
+			for _, mv := range moves {
+				want = append(want, mv.ToUCI())
+			}
+			slices.Sort(want)
+
+			legal.MainSearcher().Position = b
+			pseudo.MainSearcher().Position = b
+			for _, e := range []*Engine{legal, pseudo} {
+				if got := pickAll(e.MainSearcher(), ttMove, killer1, killer2, counter, false); !slices.Equal(got, want) {
+					t.Fatalf("TestMovePickerModesAgree (%s, pseudo-legal %t): got %v, wanted %v", b.ToFEN(), e.PseudoLegal, got, want)
+				}
+			}
+
+			qsMove := ternary(ttMove.IsCapture(), ttMove, Move{})
+			got := pickAll(pseudo.MainSearcher(), qsMove, Move{}, Move{}, Move{}, true)
+			if want := pickAll(legal.MainSearcher(), qsMove, Move{}, Move{}, Move{}, true); !slices.Equal(got, want) {
+				t.Fatalf("TestMovePickerModesAgree (%s, quiescence): got %v, wanted %v", b.ToFEN(), got, want)
+			}
+
+			earlier = append(earlier, moves...)
+			b.MakeMove(moves[rng.Intn(len(moves))])
+		}
+	}
+}
@@ -43,6 +43,7 @@ type AccumulatorPair struct {
 	white        Accumulator
 	black        Accumulator
 	dirty        bool
+	stale        bool // Recomputed from the position by the next evaluation
 	updateBuffer AccumulatorUpdate
 }
 
@@ -247,6 +248,12 @@ func (n *NNUE) ApplyLazyUpdates(b *Board) {
 		return
 	}
 
+	// Incremental updates can't start from a stale accumulator, so the position becomes the new bottom
+	if b.accumulatorStack[currIndex].stale {
+		b.ResetAccumulators(n)
+		return
+	}
+
 	for currIndex != b.accumulatorIdx {
 		update := b.accumulatorStack[currIndex+1].updateBuffer
 
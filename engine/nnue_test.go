@@ -2,6 +2,7 @@ package engine
 
 import (
 	"fmt"
+	"os"
 	"testing"
 	"time"
 )
@@ -66,7 +67,7 @@ func PerftWithNNUECheck(b *Board, depth int, t *testing.T) int {
 		for i := 0; i < HIDDEN_LAYER_SIZE; i++ {
 			if b.accumulatorStack[b.accumulatorIdx].white.values[i] != expected.white.values[i] {
 				fmt.Println(move.ToUCI())
-				b.PrintFromBitBoards()
+				b.PrintFromBitBoards(os.Stdout)
 				t.Fatalf("MakeMove - White accumulator mismatch at %d: got %d, expected %d", i, b.accumulatorStack[b.accumulatorIdx].white.values[i], expected.white.values[i])
 			}
 			if b.accumulatorStack[b.accumulatorIdx].black.values[i] != expected.black.values[i] {
@@ -103,7 +104,7 @@ func RunPerfTestsNNUECheck(t *testing.T, position string, maxDepth int, expected
 		b.InitFEN(position)
 	}
 
-	b.PrintFromBitBoards()
+	b.PrintFromBitBoards(os.Stdout)
 	fmt.Println()
 	nodes := 0
 
@@ -0,0 +1,138 @@
+package engine
+
+// GAME TERMINATION
+//
+//	Outcome tells whether the game is over in the current position and why. The automatic
+//	endings (checkmate, stalemate, a dead position, fivefold repetition and the 75-move rule)
+//	end the game on the board, while threefold repetition and the 50-move rule only allow a
+//	player to claim a draw. When several apply, the first in this order is reported: mate and
+//	stalemate take precedence over the move-count rules, as the last move stands under FIDE rules.
+//
+//	The search does not use Outcome. It treats the first repetition as a draw and uses a looser
+//	insufficient material test, which is what it needs for scoring but not for ending games.
+
+type Termination uint8
+
+const (
+	ONGOING Termination = iota
+	CHECKMATE
+	STALEMATE
+	INSUFFICIENT_MATERIAL
+	FIVEFOLD_REPETITION
+	SEVENTY_FIVE_MOVES
+	THREEFOLD_REPETITION
+	FIFTY_MOVES
+)
+
+func (t Termination) String() string {
+	switch t {
+	case CHECKMATE:
+		return "checkmate"
+	case STALEMATE:
+		return "stalemate"
+	case INSUFFICIENT_MATERIAL:
+		return "insufficient material"
+	case FIVEFOLD_REPETITION:
+		return "fivefold repetition"
+	case SEVENTY_FIVE_MOVES:
+		return "75-move rule"
+	case THREEFOLD_REPETITION:
+		return "threefold repetition"
+	case FIFTY_MOVES:
+		return "50-move rule"
+	}
+	return "ongoing"
+}
+
+type Outcome struct {
+	Termination Termination
+	Winner      Color // Only set for checkmate
+}
+
+func (o Outcome) IsOver() bool {
+	return o.Termination != ONGOING
+}
+
+func (o Outcome) IsDraw() bool {
+	return o.IsOver() && o.Termination != CHECKMATE
+}
+
+// Reports whether the game only ends if a player claims the draw
+func (o Outcome) IsClaim() bool {
+	return o.Termination == THREEFOLD_REPETITION || o.Termination == FIFTY_MOVES
+}
+
+// Result in PGN notation: 1-0, 0-1, 1/2-1/2 or * while the game goes on
+func (o Outcome) Result() string {
+	switch {
+	case !o.IsOver():
+		return "*"
+	case o.IsDraw():
+		return "1/2-1/2"
+	}
+	return ternary(o.Winner == WHITE, "1-0", "0-1")
+}
+
+// Points scored by White, which is 0.5 while the game goes on
+func (o Outcome) Score() float64 {
+	switch {
+	case !o.IsOver() || o.IsDraw():
+		return 0.5
+	}
+	return ternary(o.Winner == WHITE, 1.0, 0.0)
+}
+
+func (b *Board) Outcome() Outcome {
+	if len(b.GenerateLegalMoves()) == 0 {
+		if b.IsCheck(b.turn) {
+			return Outcome{Termination: CHECKMATE, Winner: ReverseColor(b.turn)}
+		}
+		return Outcome{Termination: STALEMATE}
+	}
+	if b.IsDeadPosition() {
+		return Outcome{Termination: INSUFFICIENT_MATERIAL}
+	}
+
+	repetitions := b.Repetitions()
+	switch {
+	case repetitions >= 5:
+		return Outcome{Termination: FIVEFOLD_REPETITION}
+	case b.plyCnt50 >= 150:
+		return Outcome{Termination: SEVENTY_FIVE_MOVES}
+	case repetitions >= 3:
+		return Outcome{Termination: THREEFOLD_REPETITION}
+	case b.plyCnt50 >= 100:
+		return Outcome{Termination: FIFTY_MOVES}
+	}
+	return Outcome{}
+}
+
+// Counts how often the current position has occurred in the game, including
+// now. Only positions since the last capture or pawn move can be the same.
+func (b *Board) Repetitions() int {
+	count := 1
+	for i := len(b.history) - 1; i >= max(0, len(b.history)-b.plyCnt50); i-- {
+		if b.history[i].hash == b.zobrist && !b.history[i].move.null {
+			count++
+		}
+	}
+	return count
+}
+
+// Reports whether neither side can ever checkmate: bare kings, a single minor
+// piece against a bare king, or only bishops on squares of one colour.
+// Unlike IsInsufficientMaterial this never includes positions where a mate
+// is still possible, such as two knights against a king.
+func (b *Board) IsDeadPosition() bool {
+	kings := b.pieces[W_K] | b.pieces[B_K]
+	bishops := b.pieces[W_B] | b.pieces[B_B]
+	others := b.occupied &^ (kings | bishops)
+
+	switch {
+	case others == 0 && (bishops&LIGHT_SQUARES == 0 || bishops&^LIGHT_SQUARES == 0):
+		return true
+	case bishops == 0 && PopCount(others) == 1:
+		return others&(b.pieces[W_N]|b.pieces[B_N]) != 0
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestOutcome(t *testing.T) {
+	InitializeEverythingExceptTTable()
+
+	tests := []struct {
+		fen    string
+		moves  []string
+		want   Termination
+		result string
+	}{
+		{"startpos", nil, ONGOING, "*"},
+		{"startpos", []string{"f2f3", "e7e5", "g2g4", "d8h4"}, CHECKMATE, "0-1"},
+		{"6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", []string{"a1a8"}, CHECKMATE, "1-0"},
+		{"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", nil, STALEMATE, "1/2-1/2"},
+
+		// Mate on the last move stands over the move-count rules
+		{"6k1/5ppp/8/8/8/8/8/R5K1 w - - 149 120", []string{"a1a8"}, CHECKMATE, "1-0"},
+		{"8/8/4k3/8/8/3K4/8/R7 w - - 99 80", []string{"a1a2"}, FIFTY_MOVES, "1/2-1/2"},
+		{"8/8/4k3/8/8/3K4/8/R7 w - - 149 100", []string{"a1a2"}, SEVENTY_FIVE_MOVES, "1/2-1/2"},
+		{"8/8/4k3/8/8/3K4/8/R7 w - - 98 80", []string{"a1a2"}, ONGOING, "*"},
+
+		{"startpos", []string{"g1f3", "g8f6", "f3g1", "f6g8", "g1f3", "g8f6", "f3g1", "f6g8"}, THREEFOLD_REPETITION, "1/2-1/2"},
+		{"startpos", []string{"g1f3", "g8f6", "f3g1", "f6g8", "g1f3", "g8f6", "f3g1"}, ONGOING, "*"},
+		{"startpos", []string{
+			"g1f3", "g8f6", "f3g1", "f6g8", "g1f3", "g8f6", "f3g1", "f6g8",
+			"g1f3", "g8f6", "f3g1", "f6g8", "g1f3", "g8f6", "f3g1", "f6g8",
+		}, FIVEFOLD_REPETITION, "1/2-1/2"},
+
+		{"8/8/4k3/8/8/3K4/8/8 w - - 0 1", nil, INSUFFICIENT_MATERIAL, "1/2-1/2"},
+		{"8/8/4k3/8/8/3KB3/8/8 w - - 0 1", nil, INSUFFICIENT_MATERIAL, "1/2-1/2"},
+		{"8/8/4k3/8/8/3KN3/8/8 w - - 0 1", nil, INSUFFICIENT_MATERIAL, "1/2-1/2"},
+		{"8/8/4kb2/8/8/3KB3/8/8 w - - 0 1", nil, INSUFFICIENT_MATERIAL, "1/2-1/2"},
+		{"8/8/4k3/8/8/2BKB3/8/8 w - - 0 1", nil, INSUFFICIENT_MATERIAL, "1/2-1/2"},
+		{"8/8/4k1b1/8/8/3KB3/8/8 w - - 0 1", nil, ONGOING, "*"},
+		{"8/8/4k3/8/2n5/3KN3/8/8 w - - 0 1", nil, ONGOING, "*"},
+		{"8/8/4k3/8/8/3KNN2/8/8 w - - 0 1", nil, ONGOING, "*"},
+		{"8/8/4kn2/8/8/3KB3/8/8 w - - 0 1", nil, ONGOING, "*"},
+		{"8/8/4k3/8/8/3KP3/8/8 w - - 0 1", nil, ONGOING, "*"},
+	}
+	for _, test := range tests {
+		b, err := NewPosition(test.fen, test.moves...)
+		if err != nil {
+			t.Fatalf("TestOutcome (%s %v): %v", test.fen, test.moves, err)
+		}
+		got := b.Outcome()
+		if got.Termination != test.want || got.Result() != test.result {
+			t.Errorf("TestOutcome (%s %v): got %v %s, wanted %v %s", test.fen, test.moves, got.Termination, got.Result(), test.want, test.result)
+		}
+		if got.IsClaim() != (test.want == THREEFOLD_REPETITION || test.want == FIFTY_MOVES) {
+			t.Errorf("TestOutcome (%s %v): wrong claim status for %v", test.fen, test.moves, got.Termination)
+		}
+	}
+}
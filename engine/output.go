@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// PROTOCOL OUTPUT
+//
+//	Everything the UCI and XBoard front-ends print goes through Output, which writes to stdout
+//	and, once a debug log is opened, copies every line there with a timestamp. Commands read by
+//	the front-ends are logged as well, so the log is a complete transcript of the session:
+//
+//	2024-01-02 15:04:05.000 >> go wtime 1000 btime 1000
+//	2024-01-02 15:04:06.000 << bestmove e2e4
+var Output = &ProtocolWriter{out: os.Stdout}
+
+type ProtocolWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+	log *os.File // Debug log, nil if disabled
+}
+
+const DEBUG_LOG_TIME_FORMAT = "2006-01-02 15:04:05.000"
+
+// Starts logging to the file at path, appending if it exists. An empty path
+// or "<empty>" stops logging.
+func (w *ProtocolWriter) SetLogFile(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.log != nil {
+		w.log.Close()
+		w.log = nil
+	}
+	if path == "" || path == "<empty>" {
+		return nil
+	}
+
+	log, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w.log = log
+	return nil
+}
+
+// Records a command read from the GUI in the debug log
+func (w *ProtocolWriter) LogInput(line string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.logLine(">>", []byte(line))
+}
+
+func (w *ProtocolWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) > 0 {
+			w.logLine("<<", bytes.TrimSuffix(line, []byte("\n")))
+		}
+	}
+	return w.out.Write(p)
+}
+
+func (w *ProtocolWriter) logLine(direction string, line []byte) {
+	if w.log != nil {
+		fmt.Fprintf(w.log, "%s %s %s\n", time.Now().Format(DEBUG_LOG_TIME_FORMAT), direction, line)
+	}
+}
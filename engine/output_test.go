@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDebugLog(t *testing.T) {
+	out := bytes.Buffer{}
+	w := &ProtocolWriter{out: &out}
+	path := filepath.Join(t.TempDir(), "debug.log")
+
+	w.Write([]byte("before the log\n"))
+	if err := w.SetLogFile(path); err != nil {
+		t.Fatal(err)
+	}
+	w.LogInput("go depth 1")
+	w.Write([]byte("info depth 1\nbestmove e2e4\n"))
+	w.SetLogFile("<empty>")
+	w.Write([]byte("after the log\n"))
+
+	if got := strings.Count(out.String(), "\n"); got != 4 {
+		t.Errorf("TestDebugLog: got %d lines of output, wanted %d", got, 4)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := []string{">> go depth 1", "<< info depth 1", "<< bestmove e2e4"}
+	if len(lines) != len(want) {
+		t.Fatalf("TestDebugLog: got log %q", lines)
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, want[i]) || len(line) != len(DEBUG_LOG_TIME_FORMAT)+1+len(want[i]) {
+			t.Errorf("TestDebugLog: got line %q, wanted a timestamp and %q", line, want[i])
+		}
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	out := bytes.Buffer{}
+	Output.mu.Lock()
+	stdout := Output.out
+	Output.out = &out
+	Output.mu.Unlock()
+	defer func() {
+		Output.mu.Lock()
+		Output.out = stdout
+		Output.mu.Unlock()
+	}()
+
+	e := NewEngine(1)
+	s := e.MainSearcher()
+	s.Position.InitStartPos()
+	s.Info.JSON = true
+	e.Timer.Calculate(WHITE, 0, 0, 0, 0, 0, 4, 0, 0, false)
+	s.SearchPosition()
+
+	depth := 0
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		info := jsonInfo{}
+		if err := json.Unmarshal([]byte(line), &info); err != nil {
+			t.Fatalf("TestJSONOutput: %q is not JSON: %v", line, err)
+		}
+		if info.Bound == "exact" {
+			depth++
+			if info.Depth != depth || len(info.PV) == 0 || info.Score.Centipawns == nil || info.Nodes == 0 {
+				t.Errorf("TestJSONOutput: got %+v", info)
+			}
+		}
+	}
+	if depth != 4 {
+		t.Errorf("TestJSONOutput: got %d iterations, wanted %d", depth, 4)
+	}
+}
+
+func TestDebugPositionOutput(t *testing.T) {
+	out := bytes.Buffer{}
+	Output.mu.Lock()
+	stdout := Output.out
+	Output.out = &out
+	Output.mu.Unlock()
+	path := filepath.Join(t.TempDir(), "debug.log")
+	if err := Output.SetLogFile(path); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		Output.SetLogFile("<empty>")
+		Output.mu.Lock()
+		Output.out = stdout
+		Output.mu.Unlock()
+	}()
+
+	uci := UCIManager{Engine: NewEngine(1)}
+	uci.Initialize()
+	uci.HandleCommand("position startpos moves e2e4")
+	uci.HandleCommand("d")
+
+	// The board goes to the protocol output and its log like every other reply
+	Output.SetLogFile("<empty>")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "A   B   C") || !strings.Contains(string(data), "A   B   C") {
+		t.Errorf("TestDebugPositionOutput: got output %q and log %q", out.String(), data)
+	}
+}
@@ -13,3 +13,63 @@ func TestSearchPosition(t *testing.T) {
 	InitializeTT(256)
 	RunSearch("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 8)
 }
+
+func TestPerftBreakdown(t *testing.T) {
+	InitializeEverythingExceptTTable()
+
+	// https://www.chessprogramming.org/Perft_Results
+	tests := []struct {
+		fen   string
+		depth int
+		want  PerftCounts
+	}{
+		{"startpos", 3, PerftCounts{8902, 34, 0, 0, 0, 12, 0, 0, 0}},
+		{"startpos", 4, PerftCounts{197281, 1576, 0, 0, 0, 469, 0, 0, 8}},
+		{"startpos", 5, PerftCounts{4865609, 82719, 258, 0, 0, 27351, 6, 0, 347}},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 2, PerftCounts{2039, 351, 1, 91, 0, 3, 0, 0, 0}},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 3, PerftCounts{97862, 17102, 45, 3162, 0, 993, 0, 0, 1}},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 4, PerftCounts{4085603, 757163, 1929, 128013, 15172, 25523, 42, 6, 43}},
+		{"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 4, PerftCounts{43238, 3348, 123, 0, 0, 1680, 106, 0, 17}},
+		{"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 5, PerftCounts{674624, 52051, 1165, 0, 0, 52950, 1292, 3, 0}},
+		{"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 3, PerftCounts{9467, 1021, 4, 0, 120, 38, 2, 0, 22}},
+		{"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 4, PerftCounts{422333, 131393, 0, 7795, 60032, 15492, 19, 0, 5}},
+	}
+	cache := NewPerftCache(PERFT_TEST_CACHE_MB)
+	for _, test := range tests {
+		b := mustPosition(t, test.fen)
+		if got := PerftDetailed(b, test.depth, nil); got != test.want {
+			t.Errorf("TestPerftBreakdown (%s, depth %d):\ngot    %v\nwanted %v", test.fen, test.depth, got, test.want)
+		}
+		if got := PerftDetailed(b, test.depth, cache); got != test.want {
+			t.Errorf("TestPerftBreakdown (%s, depth %d, cached):\ngot    %v\nwanted %v", test.fen, test.depth, got, test.want)
+		}
+	}
+}
+
+func TestPerftHashed(t *testing.T) {
+	InitializeEverythingExceptTTable()
+
+	// A small cache, so entries keep being replaced
+	cache := NewPerftCache(1)
+	tests := []struct {
+		fen   string
+		depth int
+		want  int
+	}{
+		{"startpos", 5, 4865609},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 4, 4085603},
+		{"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 6, 11030083},
+		{"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", 4, 2103487},
+		{"8/k1P5/8/1K6/8/8/8/8 w - - 0 1", 7, 567584},
+	}
+	for _, test := range tests {
+		b := mustPosition(t, test.fen)
+		fen := b.ToFEN()
+		if got := PerftHashed(b, test.depth, cache); got != test.want {
+			t.Errorf("TestPerftHashed (%s, depth %d): got %d, wanted %d", test.fen, test.depth, got, test.want)
+		}
+		if b.ToFEN() != fen {
+			t.Errorf("TestPerftHashed (%s): the board changed to %s", test.fen, b.ToFEN())
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Reads commands until quit or the end of the input. The first command
+// selects the protocol: `xboard` starts the XBoard front-end and anything
+// else the UCI one. jsonOutput makes UCI searches print JSON instead of
+// info lines.
+func RunProtocol(in io.Reader, jsonOutput bool) {
+	var handle func(command string) bool
+	commandLoop(in, func(command string) bool {
+		if handle == nil {
+			if len(strings.Fields(command)) == 0 {
+				return true
+			}
+			if strings.Fields(command)[0] == "xboard" {
+				xb := &XBoardManager{}
+				xb.Initialize()
+				handle = xb.HandleCommand
+			} else {
+				uci := &UCIManager{JSONOutput: jsonOutput}
+				uci.Initialize()
+				handle = uci.HandleCommand
+			}
+		}
+		return handle(command)
+	})
+}
+
+// Passes every line of in to handle until it returns false
+func commandLoop(in io.Reader, handle func(command string) bool) {
+	scanner := bufio.NewScanner(in)
+	// Long games produce long position commands
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	for scanner.Scan() {
+		Output.LogInput(scanner.Text())
+		if !handle(scanner.Text()) {
+			return
+		}
+	}
+}
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"fmt"
+	"os"
 )
 
 func InitializeEverythingExceptTTable() {
@@ -28,17 +29,15 @@ func Run(command string, position string, depth int) {
 }
 
 func RunSearch(position string, depth int) {
-	s := Searcher{}
-	s.Position = NewBoard()
-
-	if position != "startpos" {
-		fen := position
-		s.Position.InitFEN(fen)
-	} else {
-		s.Position.InitStartPos()
+	s := DefaultEngine.MainSearcher()
+	b, err := NewPosition(position)
+	if err != nil {
+		fmt.Println(err)
+		return
 	}
+	s.Position = b
 
-	s.Position.PrintFromBitBoards()
+	s.Position.PrintFromBitBoards(os.Stdout)
 	s.ResetInfo()
 
 	for i := 1; i <= depth; i++ {
@@ -49,7 +48,7 @@ func RunSearch(position string, depth int) {
 		searchStack := [MAX_PLY]SearchStack{}
 		score := s.Pvs(i, 1, -WIN_VAL-1, WIN_VAL+1, true, searchStack[:], &line, false)
 
-		if Timer.Stop {
+		if s.timer.Stopped() {
 			break
 		}
 
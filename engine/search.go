@@ -1,19 +1,29 @@
 package engine
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"strings"
+	"time"
 )
 
 // SearchInfo stores global search statistics which will be used
 // to report search information via UCI.
 type SearchInfo struct {
 	NodesSearched    int
+	SelDepth         int
 	PonderMove       Move
 	RootDepth        int
 	IsPondering      bool
 	PonderingEnabled bool
+	ShowWDL          bool           // Append win/draw/loss statistics to info output
+	Quiet            bool           // Suppress info output, e.g. during self-play
+	Score            int            // Score of the last completed iteration
+	Contempt         int            // How much worse than equal a draw is for the root side, in internal units
+	RandomDrawScore  bool           // Jitter draw scores by one unit to avoid repetition blindness
+	JSON             bool           // Print every iteration as a JSON object instead of an info line
+	Progress         func(Analysis) // Called for every PV and aspiration failure, if set
 	NodesPerMove     map[Move]int
 }
 
@@ -25,43 +35,79 @@ type SearchStack struct {
 	move          Move
 }
 
+// RootMove stores the score and principal variation of a root move from
+// the last completed iteration.
+type RootMove struct {
+	Move  Move
+	Score int
+	PV    []Move
+}
+
 // Searcher is the primary search thread. All information stored in
 // this struct is specific to each individual search.
 type Searcher struct {
-	Position     *Board
-	KillerMoves  [101][2]Move
-	History      [2][64][64]int
-	CounterMoves [12][64]Move
-	ContHist     [12][64][12][64]int
-	Info         SearchInfo
+	Position          *Board
+	KillerMoves       [101][2]Move
+	History           [2][64][64]int
+	CounterMoves      [12][64]Move
+	ContHist          [12][64][12][64]int
+	Info              SearchInfo
+	Skill             Skill
+	RootMoves         []RootMove  // Sorted best first, one per searched PV
+	Stats             SearchStats // Only collected with the stats build tag, see stats.go
+	excludedRootMoves []Move      // Root moves already covered by earlier PVs of this iteration
+	rootColor         Color       // Side to move at the root, used for side-relative draw scores
+
+	// Tables of the engine this searcher belongs to, see SetEngine
+	engine *Engine
+	tt     *TranspositionTable
+	timer  *TimeManager
+	nnue   *NNUE
+	params *TunableParameters
+	lmr    *[101][101]int
 }
 
 // This tables stores the pre-computed depth reductions based on
-// depth and number of explored moves.
+// depth and number of explored moves (for the default engine).
 var LMR_TABLE = [101][101]int{}
 
 // Max depth and ply we will search
 const MAX_DEPTH = 100
 const MAX_PLY = 256
 
+// Range of the Contempt option, in centipawns
+const MAX_CONTEMPT = 100
+
+// Time after which we start reporting the move currently searched at the root
+const CURRMOVE_MIN_TIME = 3000
+
 // Quiescence search - utilized at leaf nodes to mitigate the horizon effect
 // by calculating all possible captures and only computing a static evaluation
 // when the position is quiet.
-func (s *Searcher) QuiescenceSearch(alpha int, beta int) int {
+func (s *Searcher) QuiescenceSearch(alpha int, beta int, ply int) int {
 	s.Info.NodesSearched++
 
+	if ply > s.Info.SelDepth {
+		s.Info.SelDepth = ply
+	}
+
 	if s.Info.NodesSearched%2047 == 0 {
-		Timer.CheckPVS(&s.Info)
+		s.timer.CheckPVS(&s.Info)
 	}
 
-	if Timer.Stop {
+	if s.timer.Stopped() {
 		return 0
 	}
 
+	if s.Position.IsInsufficientMaterial() {
+		return s.drawScore()
+	}
+
 	ttMove := Move{}
 
 	// Probe TT in QS, see if we can get a TT cutoff or just get static eval
-	probeResult, score, entry := ProbeTT(s.Position, alpha, beta, uint8(0), &ttMove)
+	probeResult, score, entry := s.tt.Probe(s.Position, alpha, beta, uint8(0), &ttMove)
+	s.Stats.TTProbe(probeResult)
 	if probeResult == CUTOFF {
 		return score
 	}
@@ -73,7 +119,7 @@ func (s *Searcher) QuiescenceSearch(alpha int, beta int) int {
 
 	eval := -2 * WIN_VAL
 	if probeResult == NULL {
-		eval = EvaluateNNUE(s.Position)
+		eval = s.nnue.Evaluate(s.Position)
 	} else {
 		eval = int(entry.staticEval)
 	}
@@ -101,10 +147,10 @@ func (s *Searcher) QuiescenceSearch(alpha int, beta int) int {
 		}
 
 		s.Position.MakeMove(move)
-		score := -s.QuiescenceSearch(-beta, -alpha)
+		score := -s.QuiescenceSearch(-beta, -alpha, ply+1)
 		s.Position.Undo()
 
-		if Timer.Stop {
+		if s.timer.Stopped() {
 			return 0
 		}
 
@@ -130,13 +176,17 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 	s.Info.NodesSearched++
 
 	if s.Info.NodesSearched%2047 == 0 {
-		Timer.CheckPVS(&s.Info)
+		s.timer.CheckPVS(&s.Info)
 	}
 
-	if Timer.Stop {
+	if s.timer.Stopped() {
 		return 0
 	}
 
+	if ply > s.Info.SelDepth {
+		s.Info.SelDepth = ply
+	}
+
 	isPv := beta > alpha+1
 	isRoot := depth == s.Info.RootDepth
 	stm := s.Position.turn
@@ -153,15 +203,16 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 	}
 
 	if depth <= 0 || ply >= MAX_PLY {
-		return s.QuiescenceSearch(alpha, beta)
+		return s.QuiescenceSearch(alpha, beta, ply)
 	}
+	s.Stats.Node(depth)
 
-	// Check for two-fold repetition or 50 move rule. Edge case check from Blunder:
-	// ensure that mate in 1 is not possible when checking for 50-move rule.
+	// Check for two-fold repetition, 50 move rule or insufficient material. Edge case
+	// check from Blunder: ensure that mate in 1 is not possible when checking for 50-move rule.
 	possibleCheckmate := check && isRoot
-	if !isRoot && (s.Position.IsTwoFold() || (s.Position.plyCnt50 >= 100 && !possibleCheckmate)) {
+	if !isRoot && (s.Position.IsTwoFold() || (s.Position.plyCnt50 >= 100 && !possibleCheckmate) || s.Position.IsInsufficientMaterial()) {
 		// Don't store repetition positions in TT since their value depends on game history
-		return 0
+		return s.drawScore()
 	}
 
 	ttMove := Move{}
@@ -171,7 +222,8 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 	// The goal is to prune the node entirely using the saved score in TT.
 	// Even if this doesn't happen though, we can still utilize saved static eval.
 	///////////////////////////////////////////////////////////////////////////////
-	probeResult, ttScore, entry := ProbeTT(s.Position, alpha, beta, uint8(depth), &ttMove)
+	probeResult, ttScore, entry := s.tt.Probe(s.Position, alpha, beta, uint8(depth), &ttMove)
+	s.Stats.TTProbe(probeResult)
 	if probeResult == CUTOFF && !isRoot && !isPv {
 		return ttScore
 	}
@@ -185,13 +237,13 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 	///////////////////////////////////////////////////////////////////////////////
 	staticEval := -2 * WIN_VAL
 	if check || probeResult == NULL {
-		staticEval = EvaluateNNUE(s.Position)
+		staticEval = s.nnue.Evaluate(s.Position)
 	} else {
 		staticEval = int(entry.staticEval)
-		if ttScore > staticEval && (entry.bd == EXACT || entry.bd == LOWER) {
+		if ttScore > staticEval && (entry.getBound() == EXACT || entry.getBound() == LOWER) {
 			staticEval = ttScore
 		}
-		if ttScore < staticEval && (entry.bd == EXACT || entry.bd == UPPER) {
+		if ttScore < staticEval && (entry.getBound() == EXACT || entry.getBound() == UPPER) {
 			staticEval = ttScore
 		}
 	}
@@ -223,9 +275,10 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 		// Currently the margin is a constant multiple of depth, this can be improved.
 		// More info: https://www.chessprogramming.org/Reverse_Futility_Pruning
 		///////////////////////////////////////////////////////////////////////////////
-		if depth <= Params.RFP_MAX_DEPTH && !ttMove.IsEmpty() && ttMove.movetype != CAPTURE && beta > -WIN_VAL-100 {
-			margin := Params.RFP_MULT * depth
+		if depth <= s.params.RFP_MAX_DEPTH && !ttMove.IsEmpty() && ttMove.movetype != CAPTURE && beta > -WIN_VAL-100 {
+			margin := s.params.RFP_MULT * depth
 			if staticEval-margin >= beta {
+				s.Stats.Event(STAT_RFP, depth)
 				return beta + (staticEval-beta)/2
 			}
 		}
@@ -237,12 +290,14 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 		// low. However, we need to ensure that we are not searching for mate.
 		// More info: https://www.chessprogramming.org/Razoring
 		///////////////////////////////////////////////////////////////////////////////
-		if depth <= Params.RAZORING_MAX_DEPTH && alpha < WIN_VAL/10 && beta > -WIN_VAL/10 {
-			razorMargin := Params.RAZORING_MULT * depth
+		if depth <= s.params.RAZORING_MAX_DEPTH && alpha < WIN_VAL/10 && beta > -WIN_VAL/10 {
+			razorMargin := s.params.RAZORING_MULT * depth
 			if staticEval+razorMargin <= alpha {
 				// Try qsearch to verify if position is really bad
-				qScore := s.QuiescenceSearch(alpha, beta)
+				s.Stats.Event(STAT_RAZOR_TRY, depth)
+				qScore := s.QuiescenceSearch(alpha, beta, ply)
 				if qScore < alpha {
+					s.Stats.Event(STAT_RAZOR, depth)
 					return qScore
 				}
 			}
@@ -258,7 +313,8 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 		// More info: https://www.chessprogramming.org/Null_Move_Pruning
 		///////////////////////////////////////////////////////////////////////////////
 		notJustPawnsAndKing := s.Position.colors[stm] ^ (s.Position.GetColorPieces(PAWN, stm) | s.Position.GetColorPieces(KING, stm))
-		if depth >= Params.NMP_MIN_DEPTH && doNull && notJustPawnsAndKing != 0 && staticEval >= beta {
+		if depth >= s.params.NMP_MIN_DEPTH && doNull && notJustPawnsAndKing != 0 && staticEval >= beta {
+			s.Stats.Event(STAT_NMP_TRY, depth)
 			s.Position.MakeNullMove()
 			R := 4 + depth/3 + Min((staticEval-beta)/200, 3)
 			score := -s.Pvs(depth-1-R, ply+2, -beta, -beta+1, false, ss, &childPV, false)
@@ -266,11 +322,12 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 
 			childPV = []Move{}
 
-			if Timer.Stop {
+			if s.timer.Stopped() {
 				return 0
 			}
 
 			if score >= beta {
+				s.Stats.Event(STAT_NMP, depth)
 				return score
 			}
 		}
@@ -282,8 +339,8 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 	// we did not look at this position in prior searches. We can do IIR on PV
 	// nodes and cut nodes.
 	///////////////////////////////////////////////////////////////////////////////
-	if ttMove.IsEmpty() && depth >= Params.IIR_MIN_DEPTH && (isPv || cutNode) {
-		depth -= Params.IIR_DEPTH_REDUCTION
+	if ttMove.IsEmpty() && depth >= s.params.IIR_MIN_DEPTH && (isPv || cutNode) {
+		depth -= s.params.IIR_DEPTH_REDUCTION
 	}
 
 	pvMove := ttMove
@@ -313,10 +370,20 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 			break
 		}
 
+		// Root moves that already have their own PV are skipped in MultiPV search
+		if ply == 0 && s.isExcludedRootMove(move) {
+			continue
+		}
+
 		mvCnt++
 
+		// Let the GUI know which root move we are on during long searches
+		if ply == 0 && !s.Info.IsPondering && !s.Info.Quiet && !s.Info.JSON && s.timer.Delta() > CURRMOVE_MIN_TIME {
+			fmt.Fprintf(Output, "info depth %d currmove %s currmovenumber %d\n", s.Info.RootDepth, move.ToUCI(), mvCnt)
+		}
+
 		isQuiet := move.IsQuiet()
-		lmrDepth := Max(depth-LMR_TABLE[depth][mvCnt], 0)
+		lmrDepth := Max(depth-s.lmr[depth][mvCnt], 0)
 
 		if !isRoot && !isPv && bestScore > -WIN_VAL+100 {
 			///////////////////////////////////////////////////////////////////////////////
@@ -326,7 +393,8 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 			// on move number, so we apply a quadratic depth-based formula to determine at
 			// what point to start pruning moves.
 			///////////////////////////////////////////////////////////////////////////////
-			if isQuiet && depth <= Params.LMP_MAX_DEPTH && mvCnt > Params.LMP_BASE+Params.LMP_MULT*depth*depth && !check {
+			if isQuiet && depth <= s.params.LMP_MAX_DEPTH && mvCnt > s.params.LMP_BASE+s.params.LMP_MULT*depth*depth && !check {
+				s.Stats.Event(STAT_LMP, depth)
 				mp.SkipQuiets()
 				continue
 			}
@@ -338,8 +406,9 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 			// We need to ensure that we futility prune only in quiet positions.
 			// More info: https://www.chessprogramming.org/Futility_Pruning
 			///////////////////////////////////////////////////////////////////////////////
-			futilityMargin := Params.FUTILITY_MULT*lmrDepth + Params.FUTILITY_BASE
-			if isQuiet && !check && lmrDepth <= Params.FUTILITY_MAX_DEPTH && staticEval+futilityMargin <= alpha {
+			futilityMargin := s.params.FUTILITY_MULT*lmrDepth + s.params.FUTILITY_BASE
+			if isQuiet && !check && lmrDepth <= s.params.FUTILITY_MAX_DEPTH && staticEval+futilityMargin <= alpha {
+				s.Stats.Event(STAT_FUTILITY, depth)
 				mp.SkipQuiets()
 				continue
 			}
@@ -352,17 +421,20 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 			// that seem to lose material but are good. As a result we can maintain depth-
 			// based formulas for both quiet moves and capture moves.
 			///////////////////////////////////////////////////////////////////////////////
-			seeMargin := -Params.SEE_QUIET_PRUNING_MULT * lmrDepth * lmrDepth
+			seeMargin := -s.params.SEE_QUIET_PRUNING_MULT * lmrDepth * lmrDepth
 			if isQuiet && !SEE(move, s.Position, seeMargin) {
+				s.Stats.Event(STAT_SEE_QUIET, depth)
 				continue
 			}
 
-			seeMargin = -Params.SEE_CAPTURE_PRUNING_MULT * depth
+			seeMargin = -s.params.SEE_CAPTURE_PRUNING_MULT * depth
 			if move.IsCapture() && !SEE(move, s.Position, seeMargin) {
+				s.Stats.Event(STAT_SEE_CAPTURE, depth)
 				continue
 			}
 		}
 
+		s.Stats.Searched(mp.moveStage)
 		s.Position.MakeMove(move)
 		ss[ply].move = move
 		prevNodes := s.Info.NodesSearched
@@ -387,34 +459,38 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 			///////////////////////////////////////////////////////////////////////////////
 			R := 0
 
-			if depth >= Params.LMR_MIN_DEPTH && isQuiet {
-				R = LMR_TABLE[depth][mvCnt] * 1024
+			if depth >= s.params.LMR_MIN_DEPTH && isQuiet {
+				R = s.lmr[depth][mvCnt] * 1024
 
 				if s.Position.IsCheck(s.Position.turn) {
-					R -= Params.LMR_CHECK
+					R -= s.params.LMR_CHECK
 				}
 
 				if !isPv {
-					R += Params.LMR_NOT_PV
+					R += s.params.LMR_NOT_PV
 				}
 
 				if ttMove.IsCapture() {
-					R += Params.LMR_TT_CAPTURE
+					R += s.params.LMR_TT_CAPTURE
 				}
 
 				if cutNode {
-					R += Params.LMR_CUTNODE
+					R += s.params.LMR_CUTNODE
 				}
 
 				hist := mp.history[stm][move.from][move.to] + s.getContHist(ss, move, ply, 1)
 				R -= hist * 1024 / (HISTORY_MAX_BONUS)
 
 				R = Max(R/1024, 0)
+				if R > 0 {
+					s.Stats.Event(STAT_LMR, depth)
+				}
 			}
 
 			score = -s.Pvs(depth-1-R, ply+1, -alpha-1, -alpha, true, ss, &childPV, true)
 
 			if score > alpha && R > 0 {
+				s.Stats.Event(STAT_LMR_RESEARCH, depth)
 				score = -s.Pvs(depth-1, ply+1, -alpha-1, -alpha, true, ss, &childPV, !cutNode)
 				if score > alpha {
 					score = -s.Pvs(depth-1, ply+1, -beta, -alpha, true, ss, &childPV, false)
@@ -431,7 +507,7 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 			s.Info.NodesPerMove[move] = s.Info.NodesSearched - prevNodes
 		}
 
-		if Timer.Stop {
+		if s.timer.Stopped() {
 			return 0
 		}
 
@@ -441,6 +517,7 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 		}
 
 		if score >= beta {
+			s.Stats.Cutoff(mp.moveStage, mvCnt == 1)
 			ttFlag = LOWER
 			if isQuiet {
 				///////////////////////////////////////////////////////////////////////////////
@@ -484,17 +561,124 @@ func (s *Searcher) Pvs(depth int, ply int, alpha int, beta int, doNull bool, ss
 			// Calculate mate distance
 			return -WIN_VAL + (s.Info.RootDepth - depth + 1)
 		} else {
-			return 0
+			return s.drawScore()
 		}
 	}
 
-	if !Timer.Stop {
-		StoreEntry(s.Position, bestScore, ttFlag, bestMove, uint8(depth), staticEval)
+	// The root score with excluded moves is not the score of the position
+	if !s.timer.Stopped() && (ply > 0 || len(s.excludedRootMoves) == 0) {
+		s.tt.Store(s.Position, bestScore, ttFlag, bestMove, uint8(depth), staticEval)
 	}
 
 	return bestScore
 }
 
+// Score of a drawn position for the side to move. With contempt, the root
+// side considers a draw worse than equal and its opponent better. We compare
+// against the root side rather than using the ply parity since null moves
+// advance the ply by two.
+func (s *Searcher) drawScore() int {
+	score := -s.Info.Contempt
+	if s.Position.turn != s.rootColor {
+		score = s.Info.Contempt
+	}
+
+	if s.Info.RandomDrawScore {
+		score += 1 - s.Info.NodesSearched&2
+	}
+
+	return score
+}
+
+// Converts a mate score to the number of moves until mate, which is
+// negative when we are getting mated
+func mateInMoves(score int) (int, bool) {
+	if score < WIN_VAL-101 && score > -WIN_VAL+101 {
+		return 0, false
+	}
+
+	dist := WIN_VAL - Abs(score)
+	// Convert to moves from plies
+	dist = dist/2 + 1
+	if score < 0 {
+		dist *= -1
+	}
+	return dist, true
+}
+
+// One iteration as printed in JSON output mode
+type jsonInfo struct {
+	Depth    int           `json:"depth"`
+	SelDepth int           `json:"seldepth"`
+	MultiPV  int           `json:"multipv"`
+	Score    scoreResponse `json:"score"`
+	Bound    string        `json:"bound"`
+	WDL      []int         `json:"wdl,omitempty"`
+	Nodes    int           `json:"nodes"`
+	NPS      int           `json:"nps"`
+	Time     int           `json:"time"`
+	HashFull int           `json:"hashfull"`
+	PV       []string      `json:"pv"`
+}
+
+// Prints a PV of the current iteration as a single line of JSON
+func (s *Searcher) printJSONInfo(depth int, multiPV int, score int, bd Bound, pv []Move) {
+	if !s.Info.JSON || s.Info.IsPondering || s.Info.Quiet {
+		return
+	}
+
+	delta := Max(int(s.timer.Delta()), 1)
+	info := jsonInfo{
+		Depth:    depth,
+		SelDepth: s.Info.SelDepth,
+		MultiPV:  multiPV,
+		Score:    newScoreResponse(NewScore(score)),
+		Bound:    bd.String(),
+		Nodes:    s.Info.NodesSearched,
+		NPS:      s.Info.NodesSearched * 1000 / delta,
+		Time:     delta,
+		HashFull: s.tt.HashFull(),
+		PV:       []string{},
+	}
+	if s.Info.ShowWDL {
+		w, d, l := WDL_MODEL.WDL(score, WDLMaterial(s.Position))
+		info.WDL = []int{w, d, l}
+	}
+	for _, mv := range pv {
+		info.PV = append(info.PV, mv.ToUCI())
+	}
+
+	data, _ := json.Marshal(info)
+	fmt.Fprintln(Output, string(data))
+}
+
+// Passes a PV of the current iteration to the progress callback
+func (s *Searcher) reportProgress(depth int, multiPV int, score int, bd Bound, pv []Move) {
+	if s.Info.Progress == nil {
+		return
+	}
+
+	s.Info.Progress(Analysis{
+		Depth:    depth,
+		SelDepth: s.Info.SelDepth,
+		MultiPV:  multiPV,
+		Score:    NewScore(score),
+		Bound:    bd,
+		PV:       append([]Move{}, pv...),
+		Nodes:    s.Info.NodesSearched,
+		Time:     time.Duration(s.timer.Delta()) * time.Millisecond,
+	})
+}
+
+func (s *Searcher) isExcludedRootMove(move Move) bool {
+	for _, excluded := range s.excludedRootMoves {
+		if excluded == move {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Searcher) storeKillerMove(move Move, depth int) {
 	if s.KillerMoves[depth][0] != move {
 		s.KillerMoves[depth][1] = s.KillerMoves[depth][0]
@@ -555,29 +739,64 @@ func (s *Searcher) ClearCounters() {
 }
 
 func InitializeLMRTable() {
+	initLMRTable(&LMR_TABLE)
+}
+
+func initLMRTable(table *[101][101]int) {
 	for depth := 1; depth <= 100; depth++ {
 		for moveCnt := 1; moveCnt <= 100; moveCnt++ {
 			// Formula from Ethereal
-			LMR_TABLE[depth][moveCnt] = int(0.7844 + math.Log(float64(depth))*math.Log(float64(moveCnt))/2.4696)
+			table[depth][moveCnt] = int(0.7844 + math.Log(float64(depth))*math.Log(float64(moveCnt))/2.4696)
 		}
 	}
 }
 
 func (s *Searcher) ResetInfo() {
 	s.Info.NodesSearched = 0
+	s.Info.SelDepth = 0
 	s.Info.NodesPerMove = map[Move]int{}
+	s.RootMoves = nil
 }
 
+// Searches the position and returns the move to play. With a limited skill
+// this is picked among the best few root moves and may not be the best one.
 func (s *Searcher) SearchPosition() Move {
-	Timer.StartSearch()
+	s.Engine()
+	bestMove := s.iterativeDeepening()
+
+	if s.Skill.Enabled && len(s.RootMoves) > 1 {
+		bestMove = s.Skill.PickMove(s.RootMoves)
+		for _, rm := range s.RootMoves {
+			if rm.Move == bestMove && s.Info.PonderingEnabled {
+				s.Info.PonderMove = Move{}
+				if len(rm.PV) > 1 {
+					s.Info.PonderMove = rm.PV[1]
+				}
+			}
+		}
+	}
+
+	return bestMove
+}
+
+func (s *Searcher) iterativeDeepening() Move {
+	s.timer.StartSearch()
 	s.ResetInfo()
 
+	// Entries written by this search belong to a new generation
+	s.tt.IncrementAge()
+
 	line := []Move{}
 	legalMoves := s.Position.GenerateLegalMoves()
 	prevScore := 0
+	s.rootColor = s.Position.turn
+
+	// The board may have been set up with a different network than ours, and
+	// the moves of the game need no accumulators
+	s.Position.ResetAccumulators(s.nnue)
 
 	if len(legalMoves) == 1 {
-		Timer.hardLimit /= 10
+		s.timer.hardLimit /= 10
 	}
 
 	// If no legal moves, return empty move
@@ -585,79 +804,131 @@ func (s *Searcher) SearchPosition() Move {
 		return Move{}
 	}
 
+	// A limited skill searches shallower and picks among several root moves
+	multiPV := 1
+	if s.Skill.Enabled {
+		if !s.timer.infinite {
+			s.timer.LimitSearch(s.Skill.Limits())
+		}
+		multiPV = Min(SKILL_MULTI_PV, len(legalMoves))
+	}
+
 	// Set prevBest to first legal move in case search is stopped immediately
 	prevBest := legalMoves[0]
 
 	for depth := 1; depth <= MAX_DEPTH; depth++ {
 		s.Info.RootDepth = depth
+		s.Info.SelDepth = 0
+		s.excludedRootMoves = s.excludedRootMoves[:0]
 
-		// Aspiration windows
-		alpha := -WIN_VAL - 1
-		beta := WIN_VAL + 1
+		score := 0
+		rootMoves := []RootMove{}
+		shortMate := false
+
+		for pvIdx := 0; pvIdx < multiPV; pvIdx++ {
+			// Only the first PV is searched with aspiration windows and tracked in line
+			pvLine := &line
+			if pvIdx > 0 {
+				pvLine = &[]Move{}
+			}
 
-		alphaWindowSize := -Params.ASPIRATION_WINDOW_SIZE
-		betaWindowSize := Params.ASPIRATION_WINDOW_SIZE
+			// Aspiration windows
+			alpha := -WIN_VAL - 1
+			beta := WIN_VAL + 1
 
-		if depth > 5 {
-			alpha = prevScore + alphaWindowSize
-			beta = prevScore + betaWindowSize
-		}
+			alphaWindowSize := -s.params.ASPIRATION_WINDOW_SIZE
+			betaWindowSize := s.params.ASPIRATION_WINDOW_SIZE
 
-		score := 0
+			if depth > 5 && pvIdx == 0 {
+				alpha = prevScore + alphaWindowSize
+				beta = prevScore + betaWindowSize
+			}
+
+			pvScore := 0
+
+			// Aspiration window search with exponentially-widening research on fail
+			for {
+				searchStack := [MAX_PLY]SearchStack{}
+				pvScore = s.Pvs(depth, 0, alpha, beta, true, searchStack[:], pvLine, false)
+				if s.timer.Stopped() {
+					// Without a completed iteration, fall back to the PVs we do have
+					if len(s.RootMoves) == 0 && len(rootMoves) > 0 {
+						sortRootMoves(rootMoves)
+						s.RootMoves = rootMoves
+					}
+					return prevBest
+				}
 
-		// Aspiration window search with exponentially-widening research on fail
-		for {
-			searchStack := [MAX_PLY]SearchStack{}
-			score = s.Pvs(depth, 0, alpha, beta, true, searchStack[:], &line, false)
-			if Timer.Stop {
-				return prevBest
+				if pvScore <= alpha {
+					s.reportProgress(depth, pvIdx+1, pvScore, UPPER, *pvLine)
+					s.printJSONInfo(depth, pvIdx+1, pvScore, UPPER, *pvLine)
+					alpha = Max(-WIN_VAL-1, alpha+alphaWindowSize*2)
+					alphaWindowSize *= -alphaWindowSize
+					continue
+				}
+				if pvScore >= beta {
+					s.reportProgress(depth, pvIdx+1, pvScore, LOWER, *pvLine)
+					s.printJSONInfo(depth, pvIdx+1, pvScore, LOWER, *pvLine)
+					beta = Min(WIN_VAL+1, beta+betaWindowSize*2)
+					betaWindowSize *= betaWindowSize
+					continue
+				}
+				break
 			}
 
-			if score <= alpha {
-				alpha = Max(-WIN_VAL-1, alpha+alphaWindowSize*2)
-				alphaWindowSize *= -alphaWindowSize
-				continue
+			pv := *pvLine
+			rootMoves = append(rootMoves, RootMove{Move: pv[0], Score: pvScore, PV: append([]Move{}, pv...)})
+			s.excludedRootMoves = append(s.excludedRootMoves, pv[0])
+
+			if pvIdx == 0 {
+				score = pvScore
+				s.Info.Score = score
 			}
-			if score >= beta {
-				beta = Min(WIN_VAL+1, beta+betaWindowSize*2)
-				betaWindowSize *= betaWindowSize
-				continue
+			s.reportProgress(depth, pvIdx+1, pvScore, EXACT, pv)
+
+			delta := Max(int(s.timer.Delta()), 1)
+			nps := s.Info.NodesSearched * 1000 / delta
+			hashfull := s.tt.HashFull()
+
+			wdl := ""
+			if s.Info.ShowWDL {
+				w, d, l := WDL_MODEL.WDL(pvScore, WDLMaterial(s.Position))
+				wdl = fmt.Sprintf(" wdl %d %d %d", w, d, l)
+			}
+
+			multiPVInfo := ""
+			if multiPV > 1 {
+				multiPVInfo = fmt.Sprintf(" multipv %d", pvIdx+1)
 			}
-			break
-		}
 
-		delta := Max(int(Timer.Delta()), 1)
-		nps := s.Info.NodesSearched * 1000 / delta
+			dist, isMate := mateInMoves(pvScore)
+			if isMate && pvIdx == 0 && dist < 3 && dist > -3 && !s.Info.IsPondering {
+				shortMate = true
+			}
 
-		if !s.Info.IsPondering {
-			// HANDLE MATE SCORES:
-			if score >= WIN_VAL-101 || score <= -WIN_VAL+101 {
-				dist := 0
-				if score > 0 {
-					dist = WIN_VAL - score
+			if s.Info.JSON {
+				s.printJSONInfo(depth, pvIdx+1, pvScore, EXACT, pv)
+			} else if !s.Info.IsPondering && !s.Info.Quiet {
+				// HANDLE MATE SCORES:
+				if isMate {
+					fmt.Fprintf(Output, "info depth %d seldepth %d%s nodes %d time %d score mate %d%s hashfull %d nps %d pv %s\n", depth, s.Info.SelDepth, multiPVInfo, s.Info.NodesSearched, delta, dist, wdl, hashfull, nps, strings.Trim(fmt.Sprint(pv), "[]"))
 				} else {
-					dist = WIN_VAL + score
-				}
-				// Convert to moves from plies
-				dist = dist/2 + 1
-				if score < 0 {
-					dist *= -1
+					fmt.Fprintf(Output, "info depth %d seldepth %d%s nodes %d time %d score cp %d%s hashfull %d nps %d pv %s\n", depth, s.Info.SelDepth, multiPVInfo, s.Info.NodesSearched, delta, NormalizeScore(pvScore), wdl, hashfull, nps, strings.Trim(fmt.Sprint(pv), "[]"))
 				}
+			}
+		}
 
-				fmt.Printf("info depth %d nodes %d time %d score mate %d nps %d pv %s\n", depth, s.Info.NodesSearched, delta, dist, nps, strings.Trim(fmt.Sprint(line), "[]"))
+		sortRootMoves(rootMoves)
+		s.RootMoves = rootMoves
 
-				if dist < 3 && dist > -3 {
-					return line[0]
-				}
-			} else {
-				fmt.Printf("info depth %d nodes %d time %d score cp %d nps %d pv %s\n", depth, s.Info.NodesSearched, delta, score, nps, strings.Trim(fmt.Sprint(line), "[]"))
-			}
+		if shortMate {
+			return line[0]
 		}
 
-		Timer.CheckID(&s.Info, depth)
+		s.timer.CheckID(&s.Info, depth)
 
 		if depth > 1 {
-			Timer.UpdateSoftLimit(&s.Info, line[0], prevBest, score, prevScore)
+			s.timer.UpdateSoftLimit(&s.Info, line[0], prevBest, score, prevScore)
 		}
 
 		prevBest = line[0]
@@ -672,11 +943,10 @@ func (s *Searcher) SearchPosition() Move {
 			}
 		}
 
-		if Timer.Stop {
+		if s.timer.Stopped() {
 			return prevBest
 		}
 	}
 
-	IncrementTTAge()
 	return prevBest
 }
@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+func setupDrawSearcher(fen string, root Color) *Searcher {
+	InitializeEverythingExceptTTable()
+	InitializeTT(1)
+	Timer.SetMoveTime(10000)
+	Timer.StartSearch()
+
+	s := &Searcher{}
+	s.SetEngine(DefaultEngine)
+	s.Position = NewBoard()
+	s.Position.InitFEN(fen)
+	s.rootColor = root
+	s.Info.RootDepth = 10
+	s.ResetInfo()
+	return s
+}
+
+func TestContemptIsSideRelative(t *testing.T) {
+	// Black to move and stalemated
+	stalemate := "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1"
+	tests := []struct {
+		root Color
+		want int
+	}{
+		{WHITE, 50},  // The root side dislikes the draw, so the opponent likes it
+		{BLACK, -50}, // The root side is the one being stalemated
+	}
+
+	for _, test := range tests {
+		s := setupDrawSearcher(stalemate, test.root)
+		s.Info.Contempt = 50
+
+		ss := [MAX_PLY]SearchStack{}
+		line := []Move{}
+		if got := s.Pvs(1, 1, -WIN_VAL-1, WIN_VAL+1, false, ss[:], &line, false); got != test.want {
+			t.Errorf("TestContemptIsSideRelative (root %d): got %d, wanted %d", test.root, got, test.want)
+		}
+	}
+
+	// Insufficient material is scored the same way in quiescence search
+	s := setupDrawSearcher("8/8/4k3/8/8/3KN3/8/8 w - - 0 1", WHITE)
+	s.Info.Contempt = 50
+	if got := s.QuiescenceSearch(-WIN_VAL, WIN_VAL, 3); got != -50 {
+		t.Errorf("TestContemptIsSideRelative (insufficient material): got %d, wanted %d", got, -50)
+	}
+}
+
+func TestRandomDrawScore(t *testing.T) {
+	s := setupDrawSearcher("8/8/4k3/8/8/3K4/8/8 w - - 0 1", WHITE)
+	s.Info.RandomDrawScore = true
+
+	seen := map[int]bool{}
+	for nodes := 0; nodes < 8; nodes++ {
+		s.Info.NodesSearched = nodes
+		score := s.drawScore()
+		if score != 1 && score != -1 {
+			t.Errorf("TestRandomDrawScore: got %d, wanted +-1", score)
+		}
+		seen[score] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("TestRandomDrawScore: draw score was never randomized")
+	}
+}
+
+// Plays a random game that neither ends nor drifts into a draw, making a pawn
+// move or capture whenever the fifty-move counter grows and as the last move
+func longGame(t *testing.T, plies int, seed int64) []string {
+	rng := rand.New(rand.NewSource(seed))
+	b := mustPosition(t, "startpos")
+	moves := []string{}
+	for len(moves) < plies {
+		last := len(moves) == plies-1
+		quiet, pawnMoves, captures := []Move{}, []Move{}, []Move{}
+		for _, mv := range b.GenerateLegalMoves() {
+			b.MakeMove(mv)
+			ongoing := !b.Outcome().IsOver()
+			b.Undo()
+			switch {
+			case !ongoing:
+			case mv.IsCapture():
+				captures = append(captures, mv)
+			case PieceToPieceType(mv.piece) == PAWN:
+				pawnMoves = append(pawnMoves, mv)
+			default:
+				quiet = append(quiet, mv)
+			}
+		}
+		// Captures are kept for when nothing else resets the counter, so the material lasts
+		candidates := append(quiet, pawnMoves...)
+		if last || b.plyCnt50 >= 80 || len(candidates) == 0 {
+			candidates = ternary(len(pawnMoves) > 0, pawnMoves, captures)
+		}
+		if len(candidates) == 0 {
+			t.Fatalf("stuck at %d %s", len(moves), b.ToFEN())
+		}
+		mv := candidates[rng.Intn(len(candidates))]
+		b.MakeMove(mv)
+		moves = append(moves, mv.ToUCI())
+	}
+	return moves
+}
+
+func TestLongGame(t *testing.T) {
+	e := NewEngine(1)
+
+	// 600 moves each, longer than any fixed-size accumulator stack would allow
+	moves := longGame(t, 1200, 1)
+	b, err := NewPosition("startpos", moves...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.plyCnt50 != 0 || b.Repetitions() != 1 || b.IsInsufficientMaterial() {
+		t.Fatalf("TestLongGame: the game ends in a drawish position %s", b.ToFEN())
+	}
+	fen := b.ToFEN()
+
+	s := e.MainSearcher()
+	s.Position = b
+	result, err := e.Analyse(context.Background(), Limits{Depth: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Depth != 6 || result.BestMove().IsEmpty() {
+		t.Errorf("TestLongGame: got depth %d and best move %v, wanted depth 6 and a move", result.Depth, result.BestMove())
+	}
+	if len(b.accumulatorStack) > ACCUMULATOR_STACK_SIZE {
+		t.Errorf("TestLongGame: accumulator stack grew to %d entries", len(b.accumulatorStack))
+	}
+	if got, want := e.NNUE.Evaluate(b), e.NNUE.Evaluate(mustPosition(t, fen)); got != want {
+		t.Errorf("TestLongGame: got eval %d after the search, wanted %d", got, want)
+	}
+
+	// Taking back moves made before the search still gives the right evaluation
+	for range moves {
+		b.Undo()
+	}
+	b.MakeMoveFromUCI("e2e4")
+	if got, want := e.NNUE.Evaluate(b), e.NNUE.Evaluate(mustPosition(t, "startpos", "e2e4")); got != want {
+		t.Errorf("TestLongGame: got eval %d after taking back the game, wanted %d", got, want)
+	}
+}
+
+func mustPosition(t *testing.T, fen string, moves ...string) *Board {
+	b, err := NewPosition(fen, moves...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+// Self-play games are adjudicated as draws after this many plies
+const SELFPLAY_MAX_PLIES = 400
+
+// Number of random plies played from the start position for opening variety
+const SELFPLAY_RANDOM_PLIES = 8
+
+// A position visited during a self-play game along with the search score
+// from White's perspective
+type SelfPlayPosition struct {
+	FEN   string
+	Score int
+}
+
+// Plays random legal moves from the start position to diversify openings.
+// Returns nil if the game ended during the random opening.
+func playRandomOpening(plies int, rng *rand.Rand) *Board {
+	b := NewBoard()
+	b.InitStartPos()
+	for i := 0; i < plies; i++ {
+		moves := b.GenerateLegalMoves()
+		if len(moves) == 0 {
+			return nil
+		}
+		b.MakeMove(moves[rng.Intn(len(moves))])
+	}
+	if b.Outcome().IsOver() {
+		return nil
+	}
+	return b
+}
+
+// Plays a single game between two searchers, each limited to the given
+// number of nodes per move. Returns the visited positions where the side to
+// move was not in check and the result from White's perspective.
+func PlayGame(white *Searcher, black *Searcher, nodes int, rng *rand.Rand) ([]SelfPlayPosition, float64) {
+	b := playRandomOpening(SELFPLAY_RANDOM_PLIES, rng)
+	for b == nil {
+		b = playRandomOpening(SELFPLAY_RANDOM_PLIES, rng)
+	}
+
+	for _, s := range []*Searcher{white, black} {
+		s.Position = b
+		s.Info.Quiet = true
+		s.ClearHistory()
+		s.ClearContHist()
+		s.ClearKillers()
+		s.ClearCounters()
+		s.Engine().TT.Clear()
+	}
+	sharedTT := white.Engine().TT == black.Engine().TT
+
+	positions := []SelfPlayPosition{}
+	for ply := 0; ply < SELFPLAY_MAX_PLIES; ply++ {
+		if outcome := b.Outcome(); outcome.IsOver() {
+			return positions, outcome.Score()
+		}
+
+		// Different players sharing a TT must not see each other's analysis
+		if white != black && sharedTT {
+			white.Engine().TT.Clear()
+		}
+
+		s := ternary(b.turn == WHITE, white, black)
+		s.Engine().Timer.Calculate(b.turn, 0, 0, 0, 0, 0, 0, int64(nodes), 0, false)
+		move := s.SearchPosition()
+
+		if !b.IsCheck(b.turn) {
+			positions = append(positions, SelfPlayPosition{
+				FEN:   b.ToFEN(),
+				Score: s.Info.Score * COLOR_SIGN[b.turn],
+			})
+		}
+
+		b.MakeMove(move)
+	}
+
+	return positions, 0.5
+}
+
+// Plays self-play games at a fixed node count and writes every non-mate
+// position in the `fen | score | result` format used for training data, with
+// the score and result from White's perspective.
+func GenerateSelfPlayData(games int, nodes int, seed int64, w io.Writer) error {
+	rng := rand.New(rand.NewSource(seed))
+	s := Searcher{}
+
+	for game := 1; game <= games; game++ {
+		positions, result := PlayGame(&s, &s, nodes, rng)
+
+		for _, pos := range positions {
+			if Abs(pos.Score) >= WIN_VAL-TT_MATE_ZONE {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s | %d | %.1f\n", pos.FEN, pos.Score, result); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(Output, "info string game %d/%d finished: %.1f after %d plies\n", game, games, result, len(positions))
+	}
+
+	return nil
+}
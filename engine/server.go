@@ -0,0 +1,309 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ANALYSIS SERVER
+//
+//	`maelstrom serve` exposes the analysis API over HTTP with JSON bodies:
+//
+//	POST /analyse         {"fen", "moves", "depth", "nodes", "movetime"} -> best line
+//	POST /analyse/stream  same request, streams every iteration as server-sent events
+//	POST /eval            {"fen", "moves"} -> static NNUE evaluation
+//	GET  /legal-moves     ?fen=...&moves=e2e4,e7e5 -> legal moves in UCI notation
+//
+//	Searches run on a fixed pool of engines, which bounds how many run at once. Requests wait
+//	for a free engine until the client gives up. Every search is capped at the maximum move time,
+//	so a request without limits cannot keep an engine busy forever. The engine's tables are
+//	cleared before each search, so the same request gives the same result whichever engine
+//	runs it and whatever ran before.
+type AnalysisServer struct {
+	engines     chan *Engine
+	maxMoveTime time.Duration
+}
+
+// Creates a server with a pool of engines, each with its own hash table of
+// hashMB megabytes
+func NewAnalysisServer(engines int, hashMB int, maxMoveTime time.Duration) *AnalysisServer {
+	srv := &AnalysisServer{
+		engines:     make(chan *Engine, engines),
+		maxMoveTime: maxMoveTime,
+	}
+	for i := 0; i < engines; i++ {
+		srv.engines <- NewEngine(hashMB)
+	}
+	return srv
+}
+
+type positionRequest struct {
+	FEN   string   `json:"fen"` // Empty for the start position
+	Moves []string `json:"moves"`
+}
+
+type analyseRequest struct {
+	positionRequest
+	Depth    int   `json:"depth"`
+	Nodes    int   `json:"nodes"`
+	MoveTime int64 `json:"movetime"` // Milliseconds
+}
+
+type scoreResponse struct {
+	Centipawns *int `json:"cp,omitempty"`
+	Mate       *int `json:"mate,omitempty"`
+}
+
+// Only one of the fields is set
+func newScoreResponse(sc Score) scoreResponse {
+	if sc.Mate != 0 {
+		return scoreResponse{Mate: &sc.Mate}
+	}
+	return scoreResponse{Centipawns: &sc.Centipawns}
+}
+
+type analysisResponse struct {
+	Depth    int           `json:"depth"`
+	SelDepth int           `json:"seldepth"`
+	Score    scoreResponse `json:"score"`
+	Bound    string        `json:"bound"`
+	BestMove string        `json:"bestmove"`
+	PV       []string      `json:"pv"`
+	Nodes    int           `json:"nodes"`
+	Time     int64         `json:"time"` // Milliseconds
+}
+
+func newAnalysisResponse(a Analysis) analysisResponse {
+	resp := analysisResponse{
+		Depth:    a.Depth,
+		SelDepth: a.SelDepth,
+		Score:    newScoreResponse(a.Score),
+		Bound:    a.Bound.String(),
+		BestMove: a.BestMove().ToUCI(),
+		PV:       []string{},
+		Nodes:    a.Nodes,
+		Time:     a.Time.Milliseconds(),
+	}
+	for _, mv := range a.PV {
+		resp.PV = append(resp.PV, mv.ToUCI())
+	}
+	return resp
+}
+
+func (srv *AnalysisServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/analyse", srv.handleAnalyse)
+	mux.HandleFunc("/analyse/stream", srv.handleAnalyseStream)
+	mux.HandleFunc("/eval", srv.handleEval)
+	mux.HandleFunc("/legal-moves", srv.handleLegalMoves)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// Takes an engine from the pool, waiting until one is free or the request
+// is cancelled
+func (srv *AnalysisServer) acquire(ctx context.Context) (*Engine, error) {
+	select {
+	case e := <-srv.engines:
+		return e, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (srv *AnalysisServer) release(e *Engine) {
+	e.Progress = nil
+	srv.engines <- e
+}
+
+// Request bodies are small JSON objects, anything larger is refused
+const MAX_REQUEST_BYTES = 1 << 20
+
+// Decodes a POST body into req, writing an error response on failure
+func decodeRequest(w http.ResponseWriter, r *http.Request, req any) bool {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use POST"))
+		return false
+	}
+	body := http.MaxBytesReader(w, r.Body, MAX_REQUEST_BYTES)
+	if err := json.NewDecoder(body).Decode(req); err != nil {
+		tooLarge := &http.MaxBytesError{}
+		status := ternary(errors.As(err, &tooLarge), http.StatusRequestEntityTooLarge, http.StatusBadRequest)
+		writeError(w, status, fmt.Errorf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// Runs the analysis described by req on an engine from the pool. progress,
+// if not nil, receives every iteration. Returns the HTTP status on error.
+func (srv *AnalysisServer) analyse(ctx context.Context, req analyseRequest, progress func(Analysis)) (Analysis, int, error) {
+	if req.Depth < 0 || req.Nodes < 0 || req.MoveTime < 0 {
+		return Analysis{}, http.StatusBadRequest, fmt.Errorf("limits must not be negative")
+	}
+	b, err := NewPosition(req.FEN, req.Moves...)
+	if err != nil {
+		return Analysis{}, http.StatusBadRequest, err
+	}
+
+	limits := Limits{Depth: Min(req.Depth, MAX_DEPTH), Nodes: req.Nodes, MoveTime: time.Duration(req.MoveTime) * time.Millisecond}
+	if limits.MoveTime == 0 || limits.MoveTime > srv.maxMoveTime {
+		limits.MoveTime = srv.maxMoveTime
+	}
+
+	e, err := srv.acquire(ctx)
+	if err != nil {
+		return Analysis{}, http.StatusServiceUnavailable, err
+	}
+	defer srv.release(e)
+
+	e.NewGame()
+	e.MainSearcher().Position = b
+	e.Progress = progress
+	result, err := e.Analyse(ctx, limits)
+	if errors.Is(err, ErrNoLegalMoves) {
+		return Analysis{}, http.StatusUnprocessableEntity, err
+	} else if err != nil {
+		return Analysis{}, http.StatusServiceUnavailable, err
+	}
+	return result, http.StatusOK, nil
+}
+
+func (srv *AnalysisServer) handleAnalyse(w http.ResponseWriter, r *http.Request) {
+	req := analyseRequest{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	result, status, err := srv.analyse(r.Context(), req, nil)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAnalysisResponse(result))
+}
+
+// Streams `info` events for every iteration and a final `bestmove` event
+func (srv *AnalysisServer) handleAnalyseStream(w http.ResponseWriter, r *http.Request) {
+	req := analyseRequest{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	started := false
+	send := func(event string, v any) {
+		if !started {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+	}
+
+	result, status, err := srv.analyse(r.Context(), req, func(a Analysis) {
+		send("info", newAnalysisResponse(a))
+	})
+	if err != nil {
+		if !started {
+			writeError(w, status, err)
+		} else {
+			send("error", map[string]string{"error": err.Error()})
+		}
+		return
+	}
+	send("bestmove", newAnalysisResponse(result))
+}
+
+func (srv *AnalysisServer) handleEval(w http.ResponseWriter, r *http.Request) {
+	req := positionRequest{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	b, err := NewPosition(req.FEN, req.Moves...)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	e, err := srv.acquire(r.Context())
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	eval := e.NNUE.Evaluate(b)
+	srv.release(e)
+
+	// Raw and normalised evaluation from the point of view of the side to move
+	writeJSON(w, http.StatusOK, map[string]int{"eval": eval, "cp": NormalizeScore(eval)})
+}
+
+func (srv *AnalysisServer) handleLegalMoves(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use GET"))
+		return
+	}
+
+	moves := []string{}
+	if played := r.URL.Query().Get("moves"); played != "" {
+		moves = strings.Split(played, ",")
+	}
+	b, err := NewPosition(r.URL.Query().Get("fen"), moves...)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	legal := []string{}
+	for _, mv := range b.GenerateLegalMoves() {
+		legal = append(legal, mv.ToUCI())
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"moves": legal})
+}
+
+// Entry point for `maelstrom serve`
+func RunServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:8080", "address to listen on")
+	engines := flags.Int("engines", 2, "number of searches that may run at once")
+	hash := flags.Int("hash", 64, "hash table size per engine in MB")
+	maxMoveTime := flags.Duration("max-movetime", 30*time.Second, "longest time a single search may take")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *engines < 1 {
+		return fmt.Errorf("need at least one engine")
+	}
+	if *hash < 1 || *hash > MAX_HASH {
+		return fmt.Errorf("hash must be between 1 and %d MB", MAX_HASH)
+	}
+	if *maxMoveTime <= 0 {
+		return fmt.Errorf("max-movetime must be positive")
+	}
+
+	srv := NewAnalysisServer(*engines, *hash, *maxMoveTime)
+	fmt.Fprintf(Output, "serving analysis on http://%s with %d engines\n", *addr, *engines)
+	return http.ListenAndServe(*addr, srv.Handler())
+}
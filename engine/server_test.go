@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func postJSON(t *testing.T, url string, body string) *http.Response {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST %s: %v", url, err)
+	}
+	return resp
+}
+
+func TestServerAnalyse(t *testing.T) {
+	srv := httptest.NewServer(NewAnalysisServer(2, 1, 5*time.Second).Handler())
+	defer srv.Close()
+
+	resp := postJSON(t, srv.URL+"/analyse", `{"fen": "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", "depth": 4}`)
+	defer resp.Body.Close()
+	result := analysisResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("TestServerAnalyse: status %d, err %v", resp.StatusCode, err)
+	}
+	if result.BestMove != "a1a8" || result.Score.Mate == nil || *result.Score.Mate <= 0 || result.Bound != "exact" {
+		t.Errorf("TestServerAnalyse: got %+v", result)
+	}
+
+	bad := []string{
+		`{"fen": "8/8/8/8 w - - 0 1", "depth": 2}`,
+		`{"moves": ["e2e5"], "depth": 2}`,
+		`{"depth": -1}`,
+		`not json`,
+	}
+	for _, body := range bad {
+		resp := postJSON(t, srv.URL+"/analyse", body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("TestServerAnalyse (%s): got status %d, wanted %d", body, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+
+	huge := postJSON(t, srv.URL+"/analyse", `{"fen": "`+strings.Repeat(" ", MAX_REQUEST_BYTES)+`"}`)
+	huge.Body.Close()
+	if huge.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("TestServerAnalyse (huge body): got status %d, wanted %d", huge.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestServerConcurrency(t *testing.T) {
+	srv := httptest.NewServer(NewAnalysisServer(2, 1, 5*time.Second).Handler())
+	defer srv.Close()
+
+	// More requests than engines wait for their turn
+	var wg sync.WaitGroup
+	statuses := make([]int, 5)
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp := postJSON(t, srv.URL+"/analyse", `{"moves": ["e2e4"], "nodes": 20000}`)
+			resp.Body.Close()
+			statuses[i] = resp.StatusCode
+		}(i)
+	}
+	wg.Wait()
+
+	for i, status := range statuses {
+		if status != http.StatusOK {
+			t.Errorf("TestServerConcurrency: request %d got status %d", i, status)
+		}
+	}
+}
+
+func TestServerRepeatable(t *testing.T) {
+	srv := httptest.NewServer(NewAnalysisServer(1, 1, 5*time.Second).Handler())
+	defer srv.Close()
+
+	// The second search would be much smaller if it reused the tables of the first
+	results := []analysisResponse{}
+	for _, body := range []string{`{"moves": ["e2e4"], "depth": 7}`, `{"moves": ["d2d4"], "depth": 7}`, `{"moves": ["e2e4"], "depth": 7}`} {
+		resp := postJSON(t, srv.URL+"/analyse", body)
+		result := analysisResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("TestServerRepeatable (%s): status %d, err %v", body, resp.StatusCode, err)
+		}
+		resp.Body.Close()
+		results = append(results, result)
+	}
+	if first, again := results[0], results[2]; first.Nodes != again.Nodes || !slices.Equal(first.PV, again.PV) {
+		t.Errorf("TestServerRepeatable: got %d nodes and %v, wanted %d nodes and %v", again.Nodes, again.PV, first.Nodes, first.PV)
+	}
+}
+
+func TestServerStream(t *testing.T) {
+	srv := httptest.NewServer(NewAnalysisServer(1, 1, 5*time.Second).Handler())
+	defer srv.Close()
+
+	resp := postJSON(t, srv.URL+"/analyse/stream", `{"depth": 5}`)
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("TestServerStream: got content type %q", got)
+	}
+
+	events := []string{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if event, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			events = append(events, event)
+		}
+	}
+	if len(events) < 6 || events[0] != "info" || events[len(events)-1] != "bestmove" {
+		t.Errorf("TestServerStream: got events %v", events)
+	}
+}
+
+func TestServerPositionEndpoints(t *testing.T) {
+	srv := httptest.NewServer(NewAnalysisServer(1, 1, 5*time.Second).Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/legal-moves?moves=e2e4,e7e5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legal := map[string][]string{}
+	json.NewDecoder(resp.Body).Decode(&legal)
+	resp.Body.Close()
+	if len(legal["moves"]) != 29 {
+		t.Errorf("TestServerPositionEndpoints (legal-moves): got %d moves, wanted %d", len(legal["moves"]), 29)
+	}
+
+	resp = postJSON(t, srv.URL+"/eval", `{"fen": "4k3/8/8/8/8/8/8/QQQQK3 w - - 0 1"}`)
+	eval := map[string]int{}
+	json.NewDecoder(resp.Body).Decode(&eval)
+	resp.Body.Close()
+	if eval["eval"] <= 0 || eval["cp"] <= 0 {
+		t.Errorf("TestServerPositionEndpoints (eval): got %v", eval)
+	}
+
+	resp, err = http.Get(srv.URL + "/eval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("TestServerPositionEndpoints (method): got status %d", resp.StatusCode)
+	}
+}
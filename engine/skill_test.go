@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestSkillLevelFromElo(t *testing.T) {
+	if got := SkillLevelFromElo(SKILL_MIN_ELO - 100); got != 0 {
+		t.Errorf("TestSkillLevelFromElo (min): got %.2f, wanted %d", got, 0)
+	}
+
+	top := SKILL_ELO_LADDER[len(SKILL_ELO_LADDER)-1]
+	if got := SkillLevelFromElo(SKILL_MAX_ELO); got != top.Level || got >= MAX_SKILL_LEVEL {
+		t.Errorf("TestSkillLevelFromElo (max): got %.2f, wanted %.2f", got, top.Level)
+	}
+
+	prev := -1.0
+	for elo := SKILL_MIN_ELO; elo <= SKILL_MAX_ELO; elo += 50 {
+		level := SkillLevelFromElo(elo)
+		if level < prev {
+			t.Errorf("TestSkillLevelFromElo (monotonic): level dropped from %.2f to %.2f at %d elo", prev, level, elo)
+		}
+		prev = level
+	}
+}
+
+func TestSkillLimits(t *testing.T) {
+	sk := Skill{}
+	prevDepth, prevNodes := int64(0), int64(0)
+	for level := 0; level < MAX_SKILL_LEVEL; level++ {
+		sk.SetLevel(float64(level))
+		if !sk.Enabled {
+			t.Errorf("TestSkillLimits: level %d should be enabled", level)
+		}
+
+		depth, nodes := sk.Limits()
+		if depth <= prevDepth || nodes <= prevNodes {
+			t.Errorf("TestSkillLimits: limits at level %d (%d, %d) not above previous level (%d, %d)", level, depth, nodes, prevDepth, prevNodes)
+		}
+		prevDepth, prevNodes = depth, nodes
+	}
+
+	sk.SetLevel(MAX_SKILL_LEVEL)
+	if sk.Enabled {
+		t.Errorf("TestSkillLimits: full strength should not be limited")
+	}
+}
+
+func TestSkillPickMove(t *testing.T) {
+	b := Board{}
+	b.InitStartPos()
+	moves := b.GenerateLegalMoves()
+	rootMoves := []RootMove{
+		{Move: moves[0], Score: 40},
+		{Move: moves[1], Score: 30},
+		{Move: moves[2], Score: 20},
+		{Move: moves[3], Score: -600},
+	}
+
+	// The weakest level should not always play the same move
+	sk := Skill{}
+	sk.SetLevel(0)
+	sk.Seed(1)
+	picked := map[Move]int{}
+	for i := 0; i < 200; i++ {
+		picked[sk.PickMove(rootMoves)]++
+	}
+	if len(picked) < 2 {
+		t.Errorf("TestSkillPickMove (weak): always played the same move")
+	}
+
+	// A strong level should not give away a large advantage
+	rootMoves[0].Score = 400
+	sk.SetLevel(19)
+	for i := 0; i < 200; i++ {
+		if move := sk.PickMove(rootMoves); move != moves[0] {
+			t.Fatalf("TestSkillPickMove (strong): got %s, wanted %s", move, moves[0])
+		}
+	}
+}
+
+func TestSkillSearchRootMoves(t *testing.T) {
+	InitializeEverythingExceptTTable()
+	InitializeTT(16)
+
+	s := Searcher{}
+	s.Position = NewBoard()
+	s.Position.InitFEN("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	s.Info.Quiet = true
+	s.Skill.SetLevel(6)
+	s.Skill.Seed(1)
+
+	Timer.Calculate(WHITE, 0, 0, 0, 0, 0, 0, 0, 0, false)
+	Timer.SetMoveTime(10000)
+	move := s.SearchPosition()
+
+	if len(s.RootMoves) != SKILL_MULTI_PV {
+		t.Fatalf("TestSkillSearchRootMoves: got %d root moves, wanted %d", len(s.RootMoves), SKILL_MULTI_PV)
+	}
+
+	found := false
+	seen := map[Move]bool{}
+	for i, rm := range s.RootMoves {
+		if seen[rm.Move] || rm.PV[0] != rm.Move {
+			t.Errorf("TestSkillSearchRootMoves: bad root move %s with pv %v", rm.Move, rm.PV)
+		}
+		if i > 0 && rm.Score > s.RootMoves[i-1].Score {
+			t.Errorf("TestSkillSearchRootMoves: root moves not sorted: %d before %d", s.RootMoves[i-1].Score, rm.Score)
+		}
+		seen[rm.Move] = true
+		found = found || rm.Move == move
+	}
+	if !found {
+		t.Errorf("TestSkillSearchRootMoves: played %s which is not a root move", move)
+	}
+
+	// The node cap of the level should have stopped the search
+	if _, nodes := s.Skill.Limits(); s.Info.NodesSearched > int(nodes)+MAX_PLY {
+		t.Errorf("TestSkillSearchRootMoves: searched %d nodes with a cap of %d", s.Info.NodesSearched, nodes)
+	}
+}
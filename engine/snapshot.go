@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Returns an independent copy of the board. The history and the accumulators
+// in use are copied, so moves made or taken back on either board never
+// affect the other. Unlike copying the struct, which shares both slices.
+func (b *Board) Clone() *Board {
+	c := *b
+	c.history = slices.Clone(b.history)
+	c.accumulatorStack = make([]AccumulatorPair, b.accumulatorIdx+1, max(b.accumulatorIdx+1, ACCUMULATOR_STACK_SIZE))
+	copy(c.accumulatorStack, b.accumulatorStack[:b.accumulatorIdx+1])
+	return &c
+}
+
+// POSITION SNAPSHOTS
+//
+//	A Snapshot is the plain data needed to recreate a position elsewhere, for example in another
+//	goroutine or, after encoding it as JSON, in another process. Besides the pieces and the state
+//	it carries the hashes of the earlier positions of the game, so repetitions are still detected
+//	after restoring it. The moves themselves are not kept, so a restored board cannot take back
+//	moves made before the snapshot.
+type Snapshot struct {
+	Squares        [64]Piece `json:"squares"` // EMPTY for empty squares
+	Turn           Color     `json:"turn"`
+	CastlingRights uint8     `json:"castling"` // Combination of the WHITE_K_CASTLE... flags
+	EnPassant      Square    `json:"ep"`       // EMPTY_SQ if none
+	PlyCnt         int       `json:"ply"`
+	PlyCnt50       int       `json:"ply50"`
+	MoveCount      int       `json:"moves"`
+	WhiteCastled   bool      `json:"whiteCastled"`
+	BlackCastled   bool      `json:"blackCastled"`
+	Hash           uint64    `json:"hash"`
+	History        []uint64  `json:"history"` // Hashes of the earlier positions, oldest first, 0 for null moves
+}
+
+var ErrBadSnapshot = errors.New("invalid snapshot")
+
+func (b *Board) Snapshot() Snapshot {
+	snap := Snapshot{
+		Squares:        b.squares,
+		Turn:           b.turn,
+		CastlingRights: b.castlingRights,
+		EnPassant:      b.enPassant,
+		PlyCnt:         b.plyCnt,
+		PlyCnt50:       b.plyCnt50,
+		MoveCount:      b.moveCount,
+		WhiteCastled:   b.whiteCastled,
+		BlackCastled:   b.blackCastled,
+		Hash:           uint64(b.zobrist),
+		History:        make([]uint64, len(b.history)),
+	}
+	for i, entry := range b.history {
+		if !entry.move.null {
+			snap.History[i] = uint64(entry.hash)
+		}
+	}
+	return snap
+}
+
+// Recreates the board of a snapshot. The position has to pass the same checks
+// as ParseFEN, and the hash is recomputed from the pieces and state, so a
+// snapshot that was corrupted on the way is rejected.
+func RestoreSnapshot(snap Snapshot) (*Board, error) {
+	invalid := func(format string, args ...any) (*Board, error) {
+		return nil, fmt.Errorf("%w: %s", ErrBadSnapshot, fmt.Sprintf(format, args...))
+	}
+
+	if snap.Turn != WHITE && snap.Turn != BLACK {
+		return invalid("bad side to move %d", snap.Turn)
+	}
+	if snap.CastlingRights > WHITE_K_CASTLE|WHITE_Q_CASTLE|BLACK_K_CASTLE|BLACK_Q_CASTLE {
+		return invalid("bad castling rights %d", snap.CastlingRights)
+	}
+	if snap.EnPassant != EMPTY_SQ && (snap.EnPassant < A1 || snap.EnPassant > H8) {
+		return invalid("bad en passant square %d", snap.EnPassant)
+	}
+	if snap.PlyCnt < 0 || snap.PlyCnt50 < 0 || snap.MoveCount < 0 {
+		return invalid("negative move counter")
+	}
+
+	b := NewBoard()
+	for sq := A1; sq <= H8; sq++ {
+		p := snap.Squares[sq]
+		if p > EMPTY {
+			return invalid("bad piece %d on %s", p, SQUARE_TO_STRING_MAP[sq])
+		}
+		b.putPiece(p, sq, ternary(p == EMPTY, WHITE, p.GetColor()))
+	}
+
+	b.turn = snap.Turn
+	b.castlingRights = snap.CastlingRights
+	b.OO = snap.CastlingRights&WHITE_K_CASTLE != 0
+	b.OOO = snap.CastlingRights&WHITE_Q_CASTLE != 0
+	b.oo = snap.CastlingRights&BLACK_K_CASTLE != 0
+	b.ooo = snap.CastlingRights&BLACK_Q_CASTLE != 0
+	b.enPassant = snap.EnPassant
+	b.plyCnt = snap.PlyCnt
+	b.plyCnt50 = snap.PlyCnt50
+	b.moveCount = snap.MoveCount
+	b.whiteCastled = snap.WhiteCastled
+	b.blackCastled = snap.BlackCastled
+	// Held to the same rules as a FEN, so moves are never made in an impossible position
+	if err := b.checkPosition(); err != nil {
+		err.FEN = b.ToFEN()
+		return nil, fmt.Errorf("%w: %w", ErrBadSnapshot, err)
+	}
+
+	b.zobrist ^= CASTLING_HASH[b.castlingRights]
+	if b.enPassant != EMPTY_SQ {
+		b.zobrist ^= EP_FILE_HASH[SquareToFile(b.enPassant)]
+	}
+	if b.turn == BLACK {
+		b.zobrist ^= TURN_HASH
+	}
+	if uint64(b.zobrist) != snap.Hash {
+		return invalid("hash %#x does not match the position", snap.Hash)
+	}
+
+	// Entries of null moves stay null so they never count as repetitions
+	b.history = make([]prev, len(snap.History))
+	for i, hash := range snap.History {
+		b.history[i] = prev{hash: u64(hash), move: Move{null: hash == 0}}
+	}
+
+	b.ResetAccumulators(&GlobalNNUE)
+	return b, nil
+}
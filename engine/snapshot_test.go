@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// Plays up to plies random legal moves and takes them all back
+func playAndUndoRandom(b *Board, plies int, rng *rand.Rand) {
+	played := 0
+	for ; played < plies; played++ {
+		moves := b.GenerateLegalMoves()
+		if len(moves) == 0 {
+			break
+		}
+		b.MakeMove(moves[rng.Intn(len(moves))])
+		GlobalNNUE.Evaluate(b)
+	}
+	for ; played > 0; played-- {
+		b.Undo()
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	InitializeEverythingExceptTTable()
+	rng := rand.New(rand.NewSource(1))
+
+	b := mustPosition(t, "startpos", "e2e4", "c7c5", "g1f3", "d7d6", "d2d4", "c5d4")
+	fen, hash, history := b.ToFEN(), b.zobrist, len(b.history)
+	eval := GlobalNNUE.Evaluate(b)
+
+	c := b.Clone()
+	if c.zobrist != hash || GlobalNNUE.Evaluate(c) != eval {
+		t.Fatalf("TestCloneIsIndependent: the clone is a different position")
+	}
+
+	// Going further on the clone, and back past where it was cloned
+	playAndUndoRandom(c, 40, rng)
+	c.Undo()
+	c.Undo()
+	c.MakeMoveFromUCI("b1c3")
+	if b.ToFEN() != fen || b.zobrist != hash || len(b.history) != history || GlobalNNUE.Evaluate(b) != eval {
+		t.Errorf("TestCloneIsIndependent: playing on the clone changed the original")
+	}
+	if b.history[history-1].move.ToUCI() != "c5d4" {
+		t.Errorf("TestCloneIsIndependent: the history of the original changed")
+	}
+
+	// And the other way around
+	want := c.ToFEN()
+	playAndUndoRandom(b, 40, rng)
+	b.MakeMoveFromUCI("f3d4")
+	if c.ToFEN() != want || GlobalNNUE.Evaluate(c) != GlobalNNUE.Evaluate(mustPosition(t, "startpos", "e2e4", "c7c5", "g1f3", "d7d6", "b1c3")) {
+		t.Errorf("TestCloneIsIndependent: playing on the original changed the clone")
+	}
+}
+
+func TestClonesSearchConcurrently(t *testing.T) {
+	InitializeEverythingExceptTTable()
+	b := mustPosition(t, "startpos", "d2d4", "g8f6", "c2c4", "e7e6")
+	fen, hash, eval := b.ToFEN(), b.zobrist, GlobalNNUE.Evaluate(b)
+
+	// Run with -race to catch shared state
+	var wg sync.WaitGroup
+	boards := []*Board{b}
+	for i := 0; i < 4; i++ {
+		c := b.Clone()
+		boards = append(boards, c)
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			playAndUndoRandom(c, 60, rand.New(rand.NewSource(seed)))
+		}(int64(i))
+	}
+	playAndUndoRandom(b, 60, rand.New(rand.NewSource(99)))
+	wg.Wait()
+
+	for i, board := range boards {
+		if board.ToFEN() != fen || board.zobrist != hash || GlobalNNUE.Evaluate(board) != eval {
+			t.Errorf("TestClonesSearchConcurrently (board %d): got %s, wanted %s back", i, board.ToFEN(), fen)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	InitializeEverythingExceptTTable()
+
+	// Goes through JSON like a snapshot sent to another process
+	restore := func(b *Board) *Board {
+		data, err := json.Marshal(b.Snapshot())
+		if err != nil {
+			t.Fatal(err)
+		}
+		snap := Snapshot{}
+		if err := json.Unmarshal(data, &snap); err != nil {
+			t.Fatal(err)
+		}
+		r, err := RestoreSnapshot(snap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.ToFEN() != b.ToFEN() || r.zobrist != b.zobrist || GlobalNNUE.Evaluate(r) != GlobalNNUE.Evaluate(b) {
+			t.Errorf("TestSnapshot: restored %s, wanted %s", r.ToFEN(), b.ToFEN())
+		}
+		if len(r.GenerateLegalMoves()) != len(b.GenerateLegalMoves()) {
+			t.Errorf("TestSnapshot: restored %s has different moves", r.ToFEN())
+		}
+		return r
+	}
+
+	restore(mustPosition(t, "r3k2r/8/8/3pP3/8/8/8/R3K2R w KQkq d6 0 1"))
+
+	// Twice in the position already, so one more repetition is a draw
+	b := mustPosition(t, "r3k2r/8/8/3pP3/8/8/8/R3K2R w - - 0 1", "e1f1", "e8f8", "f1e1", "f8e8", "a1b1")
+	b.MakeNullMove()
+	b.UndoNullMove()
+	r := restore(b)
+	for _, board := range []*Board{b, r} {
+		board.MakeMoveFromUCI("a8b8")
+		board.MakeMoveFromUCI("b1a1")
+		board.MakeMoveFromUCI("b8a8")
+	}
+	if got := r.Outcome().Termination; got != THREEFOLD_REPETITION || b.Outcome().Termination != got {
+		t.Errorf("TestSnapshot: got %v after repeating the position, wanted %v", got, THREEFOLD_REPETITION)
+	}
+
+	// Broken positions are turned down for the same reasons as in a FEN
+	corrupt := []struct {
+		change func(s *Snapshot)
+		want   error
+	}{
+		{func(s *Snapshot) { s.Squares[A2] = W_P }, nil},
+		{func(s *Snapshot) { s.Squares[E8] = EMPTY }, ErrFENKings},
+		{func(s *Snapshot) { s.Squares[A2] = EMPTY + 1 }, nil},
+		{func(s *Snapshot) { s.Squares[C1] = W_P }, ErrFENPawnRank},
+		{func(s *Snapshot) { s.Squares[E7] = W_R }, ErrFENOpponentInCheck},
+		{func(s *Snapshot) { s.Squares[E2] = B_K; s.Squares[E8] = EMPTY }, ErrFENOpponentInCheck},
+		{func(s *Snapshot) { s.Turn = NONE }, nil},
+		{func(s *Snapshot) { s.CastlingRights = 16 }, nil},
+		{func(s *Snapshot) { s.CastlingRights = WHITE_K_CASTLE; s.Squares[H1] = EMPTY }, ErrFENCastling},
+		{func(s *Snapshot) { s.EnPassant = EMPTY_SQ + 1 }, nil},
+		{func(s *Snapshot) { s.EnPassant = E4 }, ErrFENEnPassant},
+		{func(s *Snapshot) { s.Hash++ }, nil},
+	}
+	for i, test := range corrupt {
+		bad := b.Snapshot()
+		test.change(&bad)
+		if _, err := RestoreSnapshot(bad); !errors.Is(err, ErrBadSnapshot) || test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("TestSnapshot (corruption %d): got %v", i, err)
+		}
+	}
+}
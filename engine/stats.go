@@ -0,0 +1,37 @@
+package engine
+
+// SEARCH STATISTICS
+//
+//	Building with `-tags stats` makes every searcher count what happens inside Pvs: how often
+//	each pruning and reduction technique fires at each depth, which MovePicker stage produced
+//	the moves that failed high (and how often it was the first move searched), and how many
+//	transposition table probes hit. The `stats` command prints the counters collected so far and
+//	`bench` prints them after its run. Without the tag the counters are empty no-ops that the
+//	compiler removes, so normal builds pay nothing for them.
+
+type StatEvent uint8
+
+const (
+	STAT_RFP StatEvent = iota
+	STAT_RAZOR_TRY
+	STAT_RAZOR
+	STAT_NMP_TRY
+	STAT_NMP
+	STAT_LMP
+	STAT_FUTILITY
+	STAT_SEE_QUIET
+	STAT_SEE_CAPTURE
+	STAT_LMR
+	STAT_LMR_RESEARCH
+	NUM_STAT_EVENTS
+)
+
+var STAT_EVENT_NAMES = [NUM_STAT_EVENTS]string{
+	"rfp", "razor-try", "razor", "nmp-try", "nmp", "lmp", "futility", "see-quiet", "see-capture", "lmr", "lmr-research",
+}
+
+const NUM_STAGES = BAD_CAPTURES + 1
+
+var STAGE_NAMES = [NUM_STAGES]string{
+	"tt move", "gen captures", "good captures", "promotions", "killer 1", "killer 2", "counter", "gen quiets", "quiets", "bad captures",
+}
@@ -0,0 +1,24 @@
+//go:build !stats
+
+package engine
+
+import (
+	"fmt"
+	"io"
+)
+
+const STATS_ENABLED = false
+
+// Without the stats build tag nothing is counted, see stats.go
+type SearchStats struct{}
+
+func (st *SearchStats) Node(depth int)                 {}
+func (st *SearchStats) Event(ev StatEvent, depth int)  {}
+func (st *SearchStats) TTProbe(result ProbeResult)     {}
+func (st *SearchStats) Searched(stage Stage)           {}
+func (st *SearchStats) Cutoff(stage Stage, first bool) {}
+func (st *SearchStats) Reset()                         {}
+
+func (st *SearchStats) Print(w io.Writer) {
+	fmt.Fprintln(w, "info string search statistics are not compiled in, build with -tags stats")
+}
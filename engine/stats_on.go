@@ -0,0 +1,102 @@
+//go:build stats
+
+package engine
+
+import (
+	"fmt"
+	"io"
+)
+
+const STATS_ENABLED = true
+
+// Events deeper than this are counted in the last row
+const STATS_MAX_DEPTH = 32
+
+// Counters collected by a searcher, see stats.go
+type SearchStats struct {
+	nodes     [STATS_MAX_DEPTH]int
+	events    [STATS_MAX_DEPTH][NUM_STAT_EVENTS]int
+	searched  [NUM_STAGES]int
+	cutoffs   [NUM_STAGES]int
+	firstCuts [NUM_STAGES]int // Cutoffs by the first move searched at the node
+	ttProbes  int
+	ttHits    int
+	ttCutoffs int
+}
+
+func statsDepth(depth int) int {
+	return min(max(depth, 0), STATS_MAX_DEPTH-1)
+}
+
+// Counts a node entered by Pvs
+func (st *SearchStats) Node(depth int) {
+	st.nodes[statsDepth(depth)]++
+}
+
+func (st *SearchStats) Event(ev StatEvent, depth int) {
+	st.events[statsDepth(depth)][ev]++
+}
+
+func (st *SearchStats) TTProbe(result ProbeResult) {
+	st.ttProbes++
+	if result != NULL {
+		st.ttHits++
+	}
+	if result == CUTOFF {
+		st.ttCutoffs++
+	}
+}
+
+// Counts a move searched by Pvs, by the stage of the move picker it came from
+func (st *SearchStats) Searched(stage Stage) {
+	st.searched[stage]++
+}
+
+func (st *SearchStats) Cutoff(stage Stage, first bool) {
+	st.cutoffs[stage]++
+	if first {
+		st.firstCuts[stage]++
+	}
+}
+
+func (st *SearchStats) Reset() {
+	*st = SearchStats{}
+}
+
+func percent(part int, whole int) float64 {
+	return 100 * float64(part) / float64(Max(whole, 1))
+}
+
+func (st *SearchStats) Print(w io.Writer) {
+	fmt.Fprintf(w, "%5s %10s", "depth", "nodes")
+	for _, name := range STAT_EVENT_NAMES {
+		fmt.Fprintf(w, " %12s", name)
+	}
+	fmt.Fprintln(w)
+	for depth := range st.nodes {
+		if st.nodes[depth] == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%5d %10d", depth, st.nodes[depth])
+		for _, count := range st.events[depth] {
+			fmt.Fprintf(w, " %12d", count)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "\n%-13s %10s %10s %8s %12s\n", "stage", "searched", "cutoffs", "cutoff%", "first move%")
+	totalCuts, totalFirst := 0, 0
+	for stage := range st.searched {
+		if st.searched[stage] == 0 {
+			continue
+		}
+		totalCuts += st.cutoffs[stage]
+		totalFirst += st.firstCuts[stage]
+		fmt.Fprintf(w, "%-13s %10d %10d %8.1f %12.1f\n", STAGE_NAMES[stage], st.searched[stage], st.cutoffs[stage],
+			percent(st.cutoffs[stage], st.searched[stage]), percent(st.firstCuts[stage], st.cutoffs[stage]))
+	}
+	fmt.Fprintf(w, "fail high on the first move: %.1f%% of %d cutoffs\n", percent(totalFirst, totalCuts), totalCuts)
+
+	fmt.Fprintf(w, "tt probes: %d, hits %.1f%%, cutoffs %.1f%%\n", st.ttProbes,
+		percent(st.ttHits, st.ttProbes), percent(st.ttCutoffs, st.ttProbes))
+}
@@ -0,0 +1,48 @@
+//go:build stats
+
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSearchStats(t *testing.T) {
+	e := NewEngine(1)
+	s := e.MainSearcher()
+	s.Position.InitStartPos()
+	if _, err := e.Analyse(context.Background(), Limits{Depth: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	st := &s.Stats
+	nodes := 0
+	for _, n := range st.nodes {
+		nodes += n
+	}
+	cutoffs := 0
+	for stage := range st.cutoffs {
+		cutoffs += st.cutoffs[stage]
+		if st.cutoffs[stage] > st.searched[stage] || st.firstCuts[stage] > st.cutoffs[stage] {
+			t.Errorf("TestSearchStats (%s): %d searched, %d cutoffs, %d on the first move", STAGE_NAMES[stage], st.searched[stage], st.cutoffs[stage], st.firstCuts[stage])
+		}
+	}
+	if nodes == 0 || nodes > s.Info.NodesSearched || cutoffs == 0 || st.ttProbes == 0 {
+		t.Errorf("TestSearchStats: got %d nodes, %d cutoffs and %d probes", nodes, cutoffs, st.ttProbes)
+	}
+	if st.events[3][STAT_NMP] > st.events[3][STAT_NMP_TRY] {
+		t.Errorf("TestSearchStats: more null move cutoffs than tries")
+	}
+
+	out := strings.Builder{}
+	st.Print(&out)
+	if !strings.Contains(out.String(), "fail high on the first move") {
+		t.Errorf("TestSearchStats: got %q", out.String())
+	}
+
+	st.Reset()
+	if st.ttProbes != 0 {
+		t.Errorf("TestSearchStats: reset kept %d probes", st.ttProbes)
+	}
+}
@@ -2,10 +2,25 @@ package engine
 
 import (
 	"fmt"
+	"sync/atomic"
 	"time"
 )
 
 const INF_TIME = 10000000 // Time for `go infinite`
+
+// Default and maximum of the Move Overhead option, in milliseconds
+const DEFAULT_MOVE_OVERHEAD = 50
+const MAX_MOVE_OVERHEAD = 5000
+
+// The learned lag is multiplied by this to leave room for lag spikes
+const LAG_SAFETY_FACTOR = 2
+
+// Never plan to search for less than this, even when short on time
+const MIN_THINK_TIME = 5
+
+// Maximum of the nodestime option, in nodes per millisecond
+const MAX_NODES_TIME = 10000
+
 var MOVE_STABILITY_FACTOR = [5]float32{
 	2.5, 1.2, 0.9, 0.8, 0.75,
 }
@@ -28,14 +43,54 @@ type TimeManager struct {
 	maxDepth        int64 // Will be 0 if max depth not specified
 	maxNodes        int64 // Will be 0 if max nodes not specified
 	infinite        bool
-	moveStability   int  // Keeps track of how often the best move stays the same
-	scoreStability  int  // Keeps track of how often the best move stays the same
-	Stop            bool // If set to true, will immediately stop search
+	moveStability   int         // Keeps track of how often the best move stays the same
+	scoreStability  int         // Keeps track of how often the best move stays the same
+	stop            atomic.Bool // Once set, the search stops as soon as possible; safe to set from other goroutines
+	moveOverhead    int64
+	lag             lagTracker
+	nodesTime       int64 // Nodes per millisecond when time is measured in nodes, 0 otherwise
+	nodesClock      bool  // Whether the limits of the current search are in nodes
+	availableNodes  int64 // Our clock in nodes, carried over between moves of a game
+	nodesClockSet   bool  // Whether availableNodes has been initialized for this game
+	nodesInc        int64 // Increment in nodes for the current search
+	params          *TunableParameters
+	cancel          <-chan struct{} // Stops the search once closed, e.g. by a cancelled context
+}
+
+// LAG LEARNING
+//
+//	Time between us reading `go` and the GUI receiving `bestmove` is not only our own search time:
+//	process startup, pipes and network GUIs all add some delay that is charged to our clock. We
+//	remember the clock at the start of each search and how long the search took by our own
+//	measurement, and compare it with the clock reported in the next `go` command. Any time lost on
+//	top of our own search is tracked as a moving average, which widens the move overhead whenever it
+//	exceeds the configured value.
+type lagTracker struct {
+	color    Color
+	clock    int64 // Our remaining time when the last search started
+	inc      int64
+	elapsed  int64 // Duration of the last search as we measured it
+	hasClock bool  // Whether the last search was a timed search
+	hasTime  bool  // Whether the last search was measured and can be compared with the next clock
+	estimate int64 // Moving average of the time lost per move
 }
 
-var Timer TimeManager
+// Time manager of the default engine
+var Timer = TimeManager{moveOverhead: DEFAULT_MOVE_OVERHEAD}
+
+// Time managers that don't belong to an engine use the default parameters
+func (t *TimeManager) tunables() *TunableParameters {
+	if t.params == nil {
+		return &Params
+	}
+	return t.params
+}
 
 func (t *TimeManager) Calculate(c Color, wtime int64, btime int64, winc int64, binc int64, movestogo int64, depth int64, nodes int64, movetime int64, infinite bool) {
+	t.learnLag(c, ternary(c == WHITE, wtime, btime))
+	t.lag.hasClock = false
+	t.nodesClock = false
+
 	t.wTime = wtime
 	t.wInc = winc
 	t.bTime = btime
@@ -76,23 +131,114 @@ func (t *TimeManager) Calculate(c Color, wtime int64, btime int64, winc int64, b
 
 	remainingTime := ternary(c == WHITE, t.wTime, t.bTime)
 	increment := ternary(c == WHITE, t.wInc, t.bInc)
+	overhead := t.Overhead()
+	minTime := int64(MIN_THINK_TIME)
+
+	///////////////////////////////////////////////////////////////////////////////
+	// NODES AS TIME
+	// With nodestime set, every millisecond on the clock is worth a fixed number
+	// of nodes and the search is limited by node counts, so results don't depend
+	// on the speed or load of the machine. Like Stockfish we keep our own clock
+	// in nodes for the whole game, initialized from the first clock the GUI sends,
+	// since the GUI's clock still runs on wall time.
+	///////////////////////////////////////////////////////////////////////////////
+	if t.nodesTime > 0 {
+		if !t.nodesClockSet {
+			t.availableNodes = remainingTime * t.nodesTime
+			t.nodesClockSet = true
+		}
+		remainingTime = t.availableNodes
+		increment *= t.nodesTime
+		overhead = t.moveOverhead * t.nodesTime
+		minTime *= t.nodesTime
+		t.nodesInc = increment
+		t.nodesClock = true
+	} else {
+		t.lag = lagTracker{color: c, clock: remainingTime, inc: increment, hasClock: true, estimate: t.lag.estimate}
+	}
 
 	// General time management formula
+	params := t.tunables()
 	if t.movesToGo > 0 {
-		t.softLimit = remainingTime/t.movesToGo + increment/Params.INC_FRACTION
+		t.softLimit = remainingTime/t.movesToGo + increment/params.INC_FRACTION
 	} else {
-		t.softLimit = remainingTime/Params.TIME_DIVISOR + increment/Params.INC_FRACTION
+		t.softLimit = remainingTime/params.TIME_DIVISOR + increment/params.INC_FRACTION
 	}
 
-	t.hardLimit = t.softLimit * Params.HARD_LIMIT_MULT
+	t.hardLimit = t.softLimit * params.HARD_LIMIT_MULT
+
+	// Cap hard and soft limits to the remaining time minus the move overhead so we don't flag
+	maxTime := max(remainingTime-overhead, minTime)
+	t.hardLimit = min(t.hardLimit, maxTime)
+	t.softLimit = min(t.softLimit, maxTime)
+}
+
+func (t *TimeManager) SetMoveOverhead(overhead int64) {
+	t.moveOverhead = overhead
+}
 
-	// Cap hard and soft limits to the remainingTime - 200 so we don't flag
-	if t.hardLimit > remainingTime-200 {
-		t.hardLimit = remainingTime - 200
+// Sets how many nodes a millisecond is worth, or 0 to use wall time
+func (t *TimeManager) SetNodesTime(nodesPerMs int64) {
+	t.nodesTime = nodesPerMs
+	t.nodesClockSet = false
+}
+
+// Safety margin kept on the clock: the configured move overhead, widened
+// when we have measured more lag than that
+func (t *TimeManager) Overhead() int64 {
+	return max(t.moveOverhead, LAG_SAFETY_FACTOR*t.lag.estimate)
+}
+
+// Called once the best move has been sent, so the time we spent can be
+// compared with the clock in the next `go` command, or charged to our clock
+// in nodes
+func (t *TimeManager) FinishSearch(info *SearchInfo) {
+	if t.lag.hasClock {
+		t.lag.elapsed = t.Delta()
+		t.lag.hasTime = true
 	}
 
-	if t.softLimit > remainingTime-200 {
-		t.softLimit = remainingTime - 200
+	if t.nodesClock {
+		t.availableNodes = max(t.availableNodes+t.nodesInc-int64(info.NodesSearched), 0)
+	}
+}
+
+// Forgets everything carried over between the moves of a game: the learned
+// lag and our clock in nodes
+func (t *TimeManager) NewGame() {
+	t.lag = lagTracker{}
+	t.nodesClockSet = false
+}
+
+// Updates the lag estimate from the clock of the side to move, if the
+// previous search was ours and timed
+func (t *TimeManager) learnLag(c Color, remaining int64) {
+	if !t.lag.hasTime || c != t.lag.color || remaining <= 0 {
+		t.lag.hasTime = false
+		return
+	}
+	t.lag.hasTime = false
+
+	// Time controls with moves to go can add time, which shows up as negative lag
+	expected := t.lag.clock - t.lag.elapsed + t.lag.inc
+	lost := max(expected-remaining, 0)
+
+	// React quickly when losing time but only slowly relax the margin again
+	if lost > t.lag.estimate {
+		t.lag.estimate = (t.lag.estimate + lost + 1) / 2
+	} else {
+		t.lag.estimate -= (t.lag.estimate - lost) / 8
+	}
+}
+
+// Tightens the depth and node limits of the current search on top of
+// whatever Calculate set up. Used for strength limiting.
+func (t *TimeManager) LimitSearch(depth int64, nodes int64) {
+	if depth > 0 && (t.maxDepth == 0 || depth < t.maxDepth) {
+		t.maxDepth = depth
+	}
+	if nodes > 0 && (t.maxNodes == 0 || nodes < t.maxNodes) {
+		t.maxNodes = nodes
 	}
 }
 
@@ -106,44 +252,79 @@ func (t *TimeManager) SetMoveTime(movetime int64) {
 
 func (t *TimeManager) StartSearch() {
 	t.searchStartTime = time.Now()
-	t.Stop = false
+	t.stop.Store(false)
 	t.softScale = 1
 	t.moveStability = 0
 	t.scoreStability = 0
 }
 
+// Stops the running search. May be called from any goroutine.
+func (t *TimeManager) Stop() {
+	t.stop.Store(true)
+}
+
+func (t *TimeManager) Stopped() bool {
+	return t.stop.Load()
+}
+
+// Stops the next search as soon as done is closed. Must not be called while
+// a search is running.
+func (t *TimeManager) StopOn(done <-chan struct{}) {
+	t.cancel = done
+}
+
 func (t *TimeManager) Delta() int64 {
 	return time.Since(t.searchStartTime).Milliseconds()
 }
 
+// Search progress in the unit of the limits: milliseconds, or nodes when
+// time is measured in nodes
+func (t *TimeManager) elapsed(info *SearchInfo) int64 {
+	if t.nodesClock {
+		return int64(info.NodesSearched)
+	}
+	return t.Delta()
+}
+
 // Only called during PVS, checks hard limit timeout and nodes
 func (t *TimeManager) CheckPVS(info *SearchInfo) {
-	if t.Delta() > t.hardLimit {
-		t.Stop = true
+	if t.elapsed(info) > t.hardLimit || t.cancelled() {
+		t.Stop()
 	}
 
 	if t.maxNodes > 0 && info.NodesSearched > int(t.maxNodes) {
-		t.Stop = true
+		t.Stop()
+	}
+}
+
+func (t *TimeManager) cancelled() bool {
+	select {
+	case <-t.cancel:
+		return true
+	default:
+		return false
 	}
 }
 
 // Called during iterative deepening, checks soft limit, nodes, current depth
 // TODO: add soft time bound scaling based on ID statistics
 func (t *TimeManager) CheckID(info *SearchInfo, depth int) {
-	if t.Delta() > int64(float32(t.softLimit)*t.softScale) {
-		t.Stop = true
+	if t.elapsed(info) > int64(float32(t.softLimit)*t.softScale) || t.cancelled() {
+		t.Stop()
 	}
 
 	if t.maxNodes > 0 && info.NodesSearched > int(t.maxNodes) {
-		t.Stop = true
+		t.Stop()
 	}
 
 	if t.maxDepth > 0 && depth >= int(t.maxDepth) {
-		t.Stop = true
+		t.Stop()
 	}
 }
 
 func (t *TimeManager) UpdateSoftLimit(info *SearchInfo, bestmove Move, prevBest Move, score int, prevScore int) {
+	params := t.tunables()
+
 	///////////////////////////////////////////////////////////////////////////////
 	// SCORE STABILITY
 	// Keeping track of the best score over each iteration of IID, we can judge
@@ -152,7 +333,7 @@ func (t *TimeManager) UpdateSoftLimit(info *SearchInfo, bestmove Move, prevBest
 	// We keep track of a running count of how stable the score is in a window and
 	// then use it to index into a decreasing array of time multipliers.
 	///////////////////////////////////////////////////////////////////////////////
-	if score <= prevScore+Params.TM_STABILITY_WINDOW && score >= prevScore-Params.TM_STABILITY_WINDOW {
+	if score <= prevScore+params.TM_STABILITY_WINDOW && score >= prevScore-params.TM_STABILITY_WINDOW {
 		t.scoreStability++
 		t.scoreStability = Min(t.scoreStability, 4)
 	} else {
@@ -189,7 +370,7 @@ func (t *TimeManager) UpdateSoftLimit(info *SearchInfo, bestmove Move, prevBest
 
 	scoreStabilityFactor := SCORE_STABILITY_FACTOR[t.scoreStability]
 	moveStabilityFactor := MOVE_STABILITY_FACTOR[t.moveStability]
-	nodeRatioFactor := float32(Params.TM_NODE_COUNT_CONSTANT)/10 - float32(info.NodesPerMove[bestmove])/float32(totalNodesInIteration)
+	nodeRatioFactor := float32(params.TM_NODE_COUNT_CONSTANT)/10 - float32(info.NodesPerMove[bestmove])/float32(totalNodesInIteration)
 	t.softScale = moveStabilityFactor * scoreStabilityFactor * nodeRatioFactor
 }
 
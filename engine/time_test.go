@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestMoveOverheadLimits(t *testing.T) {
+	tm := TimeManager{}
+	tm.SetMoveOverhead(300)
+
+	// Plenty of time: the overhead doesn't matter
+	tm.Calculate(WHITE, 60000, 60000, 0, 0, 0, 0, 0, 0, false)
+	if tm.softLimit != 60000/Params.TIME_DIVISOR {
+		t.Errorf("TestMoveOverheadLimits (plenty): got soft limit %d, wanted %d", tm.softLimit, 60000/Params.TIME_DIVISOR)
+	}
+
+	// Low on time: both limits must leave the overhead on the clock
+	tm.Calculate(WHITE, 500, 60000, 2000, 0, 0, 0, 0, 0, false)
+	if tm.softLimit > 200 || tm.hardLimit > 200 {
+		t.Errorf("TestMoveOverheadLimits (low): got soft %d hard %d, wanted at most %d", tm.softLimit, tm.hardLimit, 200)
+	}
+
+	// Less time than the overhead still searches briefly
+	tm.Calculate(BLACK, 60000, 100, 0, 0, 0, 0, 0, 0, false)
+	if tm.hardLimit != MIN_THINK_TIME {
+		t.Errorf("TestMoveOverheadLimits (flagging): got hard limit %d, wanted %d", tm.hardLimit, MIN_THINK_TIME)
+	}
+}
+
+// Simulates a search that took elapsed ms by our own clock
+func simulateSearch(tm *TimeManager, c Color, clock int64, inc int64, elapsed int64) {
+	tm.Calculate(c, clock, clock, inc, inc, 0, 0, 0, 0, false)
+	tm.StartSearch()
+	tm.FinishSearch(&SearchInfo{})
+	tm.lag.elapsed = elapsed
+}
+
+func TestLagLearning(t *testing.T) {
+	tm := TimeManager{}
+	tm.SetMoveOverhead(20)
+
+	// No time lost beyond our own search
+	clock := int64(60000)
+	for i := 0; i < 5; i++ {
+		simulateSearch(&tm, WHITE, clock, 100, 500)
+		clock += 100 - 500
+	}
+	if got := tm.Overhead(); got != 20 {
+		t.Errorf("TestLagLearning (no lag): got overhead %d, wanted %d", got, 20)
+	}
+
+	// Losing 150ms on every move widens the margin
+	for i := 0; i < 5; i++ {
+		simulateSearch(&tm, WHITE, clock, 100, 500)
+		clock += 100 - 500 - 150
+	}
+	tm.Calculate(WHITE, clock, clock, 100, 100, 0, 0, 0, 0, false)
+	if got := tm.Overhead(); got < 250 || got > LAG_SAFETY_FACTOR*150 {
+		t.Errorf("TestLagLearning (lag): got overhead %d, wanted close to %d", got, LAG_SAFETY_FACTOR*150)
+	}
+
+	// The margin only relaxes slowly once the lag disappears
+	widened := tm.Overhead()
+	simulateSearch(&tm, WHITE, clock, 100, 500)
+	clock += 100 - 500
+	tm.Calculate(WHITE, clock, clock, 100, 100, 0, 0, 0, 0, false)
+	if got := tm.Overhead(); got >= widened || got < widened*3/4 {
+		t.Errorf("TestLagLearning (relax): got overhead %d after %d", got, widened)
+	}
+
+	// A clock for the other side or a new game is not compared
+	simulateSearch(&tm, WHITE, clock, 100, 500)
+	before := tm.lag.estimate
+	tm.Calculate(BLACK, clock, 1000, 100, 100, 0, 0, 0, 0, false)
+	if tm.lag.estimate != before {
+		t.Errorf("TestLagLearning (other side): estimate changed from %d to %d", before, tm.lag.estimate)
+	}
+
+	tm.NewGame()
+	if got := tm.Overhead(); got != 20 {
+		t.Errorf("TestLagLearning (reset): got overhead %d, wanted %d", got, 20)
+	}
+}
+
+func TestNodesTime(t *testing.T) {
+	tm := TimeManager{}
+	tm.SetNodesTime(100)
+
+	// 10s + 0.1s is worth 1M + 10k nodes
+	tm.Calculate(WHITE, 10000, 10000, 100, 100, 0, 0, 0, 0, false)
+	want := int64(1000000/Params.TIME_DIVISOR + 10000/Params.INC_FRACTION)
+	if tm.softLimit != want {
+		t.Fatalf("TestNodesTime (limits): got soft limit %d, wanted %d", tm.softLimit, want)
+	}
+
+	// Only the node count matters, not how long the search has been running
+	tm.stop.Store(false)
+	tm.CheckPVS(&SearchInfo{NodesSearched: 1000})
+	if tm.Stopped() {
+		t.Errorf("TestNodesTime (check): stopped after 1000 nodes with a hard limit of %d", tm.hardLimit)
+	}
+	tm.CheckPVS(&SearchInfo{NodesSearched: int(tm.hardLimit) + 1})
+	if !tm.Stopped() {
+		t.Errorf("TestNodesTime (check): did not stop after exceeding the hard limit")
+	}
+
+	// Our own node clock is used for the rest of the game, regardless of the GUI clock
+	tm.FinishSearch(&SearchInfo{NodesSearched: 60000})
+	tm.Calculate(WHITE, 1, 10000, 100, 100, 0, 0, 0, 0, false)
+	want = int64(950000/Params.TIME_DIVISOR + 10000/Params.INC_FRACTION)
+	if tm.softLimit != want {
+		t.Errorf("TestNodesTime (carry over): got soft limit %d, wanted %d", tm.softLimit, want)
+	}
+
+	// A new game starts from the GUI clock again
+	tm.NewGame()
+	tm.Calculate(WHITE, 2000, 10000, 0, 0, 0, 0, 0, 0, false)
+	want = int64(200000 / Params.TIME_DIVISOR)
+	if tm.softLimit != want {
+		t.Errorf("TestNodesTime (new game): got soft limit %d, wanted %d", tm.softLimit, want)
+	}
+
+	// Fixed move times are still measured in milliseconds
+	tm.Calculate(WHITE, 2000, 10000, 0, 0, 0, 0, 0, 100, false)
+	if tm.nodesClock {
+		t.Errorf("TestNodesTime (movetime): movetime should not be measured in nodes")
+	}
+}
@@ -1,18 +1,31 @@
 package engine
 
 import (
+	"math"
 	"math/bits"
 	"unsafe"
 )
 
-type bound uint8
+// Whether a score is exact or only an upper or lower bound on the true score
+type Bound uint8
 
 const (
-	UPPER bound = iota
+	UPPER Bound = iota
 	LOWER
 	EXACT
 )
 
+func (bd Bound) String() string {
+	switch bd {
+	case UPPER:
+		return "upper"
+	case LOWER:
+		return "lower"
+	default:
+		return "exact"
+	}
+}
+
 type ProbeResult uint8
 
 const (
@@ -21,102 +34,254 @@ const (
 	NULL
 )
 
+// Number of entries sharing a single 32-byte cluster
+const TT_CLUSTER_SIZE = 3
+
+// Scores stored in the TT are compressed to 16 bits. Anything within
+// TT_MATE_ZONE of WIN_VAL is treated as a mate score and mapped above
+// TT_MAX_SCORE, while regular scores are clamped to +/- TT_MAX_SCORE.
+const TT_MATE_ZONE = 1000
+const TT_MAX_SCORE = 30000
+const TT_MATE_SCORE = 32000
+
+// Layout of TTEntry.ageBound: bits 0-1 store the bound, bit 2 marks the
+// entry as occupied and bits 3-7 store the age (generation) of the entry.
+const (
+	TT_BOUND_MASK = 0x3
+	TT_OCCUPIED   = 0x4
+	TT_AGE_SHIFT  = 3
+	TT_AGE_CYCLE  = 1 << (8 - TT_AGE_SHIFT)
+)
+
+// Compact 10-byte TT entry. Only the lower 16 bits of the zobrist hash are
+// stored since the upper bits are used to select the cluster.
 type TTEntry struct {
-	hash       u64
-	score      int32
-	bestMove   Move
+	key        uint16
+	bestMove   PackedMove
+	score      int16
+	staticEval int16
 	depth      uint8
-	bd         bound
-	age        uint8
-	staticEval int32
+	ageBound   uint8
+}
+
+type TTCluster struct {
+	entries [TT_CLUSTER_SIZE]TTEntry
+	_       [2]byte // Pad cluster to 32 bytes
 }
 
 type TranspositionTable struct {
-	entries []TTEntry
-	count   u64
-	age     uint8 // Current age counter
+	clusters []TTCluster
+	count    u64
+	age      uint8 // Current age counter
 }
 
+// Transposition table of the default engine
 var TT TranspositionTable
 
 func InitializeTT(megabytes int) {
+	TT.Resize(megabytes)
+}
+
+func ClearTT() {
+	TT.Clear()
+}
+
+func StoreEntry(b *Board, score int, bd Bound, mv Move, depth uint8, staticEval int) {
+	TT.Store(b, score, bd, mv, depth, staticEval)
+}
+
+func ProbeTT(b *Board, alpha int, beta int, depth uint8, m *Move) (ProbeResult, int, *TTEntry) {
+	return TT.Probe(b, alpha, beta, depth, m)
+}
+
+func IncrementTTAge() {
+	TT.IncrementAge()
+}
+
+func HashFull() int {
+	return TT.HashFull()
+}
+
+// Reallocates the table with the given size, discarding all entries
+func (tt *TranspositionTable) Resize(megabytes int) {
 	// Total bytes available
 	totalBytes := uint64(megabytes) * 1024 * 1024
 
-	// Max number of entries that fit
-	entrySize := uint64(unsafe.Sizeof(TTEntry{}))
-	numEntries := totalBytes / entrySize
+	// Max number of clusters that fit. Indexing uses multiply-shift, so there
+	// is no need to round down to a power of two.
+	clusterSize := uint64(unsafe.Sizeof(TTCluster{}))
+	numClusters := totalBytes / clusterSize
+	if numClusters == 0 {
+		numClusters = 1 // avoid zero-sized allocation
+	}
+	tt.count = u64(numClusters)
 
-	// Round down to nearest power of two for better cache utilization
-	if numEntries == 0 {
-		numEntries = 1 // avoid zero-sized allocation
+	// Allocate clusters
+	tt.clusters = make([]TTCluster, tt.count)
+	tt.age = 0
+}
+
+func (tt *TranspositionTable) Clear() {
+	for i := range tt.clusters {
+		tt.clusters[i] = TTCluster{}
 	}
-	TT.count = 1 << (bits.Len64(numEntries) - 1)
+	tt.age = 0
+}
 
-	// Allocate entries
-	TT.entries = make([]TTEntry, TT.count)
-	TT.age = 0
+// Maps the hash uniformly onto [0, count) by taking the high 64 bits of the
+// 128-bit product hash * count.
+func (tt *TranspositionTable) index(hash u64) u64 {
+	idx, _ := bits.Mul64(uint64(hash), uint64(tt.count))
+	return u64(idx)
 }
 
-func ClearTT() {
-	for i := range TT.entries {
-		TT.entries[i] = TTEntry{}
+func (tt *TranspositionTable) clusterFor(hash u64) *TTCluster {
+	return &tt.clusters[tt.index(hash)]
+}
+
+func (e *TTEntry) getBound() Bound {
+	return Bound(e.ageBound & TT_BOUND_MASK)
+}
+
+func (e *TTEntry) getAge() uint8 {
+	return e.ageBound >> TT_AGE_SHIFT
+}
+
+func (e *TTEntry) isOccupied() bool {
+	return e.ageBound&TT_OCCUPIED != 0
+}
+
+// How many searches ago this entry was last written or accessed, given the
+// current age of the table
+func (e *TTEntry) relativeAge(age uint8) int {
+	return int((TT_AGE_CYCLE + age - e.getAge()) % TT_AGE_CYCLE)
+}
+
+// Entries with the lowest value are the first to be replaced: shallow
+// entries and entries from older searches are worth the least.
+func (e *TTEntry) replaceValue(age uint8) int {
+	return int(e.depth) - 8*e.relativeAge(age)
+}
+
+func scoreToTT(score int) int16 {
+	if score > WIN_VAL-TT_MATE_ZONE {
+		return int16(Min(TT_MATE_SCORE-(WIN_VAL-score), math.MaxInt16))
+	}
+	if score < -WIN_VAL+TT_MATE_ZONE {
+		return int16(Max(-TT_MATE_SCORE+(WIN_VAL+score), math.MinInt16))
 	}
-	TT.age = 0
-}
-
-func StoreEntry(b *Board, score int, bd bound, mv Move, depth uint8, staticEval int) {
-	entryIndex := b.zobrist % TT.count
-	entry := &TT.entries[entryIndex]
-
-	// Replace if:
-	// 1. We have a deeper search
-	// 2. Newer entry
-	if entry.depth <= depth || entry.depth != TT.age {
-		*entry = TTEntry{
-			bestMove:   mv,
-			hash:       b.zobrist,
-			bd:         bd,
-			score:      int32(score),
-			depth:      depth,
-			age:        TT.age,
-			staticEval: int32(staticEval),
+	return int16(Clamp(score, -TT_MAX_SCORE, TT_MAX_SCORE))
+}
+
+func scoreFromTT(score int16) int {
+	s := int(score)
+	if s > TT_MATE_SCORE-TT_MATE_ZONE {
+		return WIN_VAL - (TT_MATE_SCORE - s)
+	}
+	if s < -TT_MATE_SCORE+TT_MATE_ZONE {
+		return -WIN_VAL + (TT_MATE_SCORE + s)
+	}
+	return s
+}
+
+func (tt *TranspositionTable) Store(b *Board, score int, bd Bound, mv Move, depth uint8, staticEval int) {
+	cluster := tt.clusterFor(b.zobrist)
+	key := uint16(b.zobrist)
+
+	// Prefer an empty slot or the slot already holding this position.
+	// Otherwise replace the entry with the lowest depth-minus-age value.
+	entry := &cluster.entries[0]
+	for i := range cluster.entries {
+		candidate := &cluster.entries[i]
+		if !candidate.isOccupied() || candidate.key == key {
+			entry = candidate
+			break
+		}
+		if candidate.replaceValue(tt.age) < entry.replaceValue(tt.age) {
+			entry = candidate
 		}
 	}
+
+	sameKey := entry.isOccupied() && entry.key == key
+
+	// Keep the old best move if we don't have one for this position
+	packed := mv.Pack()
+	if sameKey && mv.IsEmpty() {
+		packed = entry.bestMove
+	}
+
+	// Don't let a shallow non-exact result from the current search
+	// overwrite a deeper result for the same position
+	if sameKey && bd != EXACT && int(depth)+4 <= int(entry.depth) && entry.getAge() == tt.age {
+		entry.bestMove = packed
+		return
+	}
+
+	*entry = TTEntry{
+		key:        key,
+		bestMove:   packed,
+		score:      scoreToTT(score),
+		staticEval: int16(Clamp(staticEval, -TT_MAX_SCORE, TT_MAX_SCORE)),
+		depth:      depth,
+		ageBound:   tt.age<<TT_AGE_SHIFT | TT_OCCUPIED | uint8(bd),
+	}
 }
 
-func ProbeTT(b *Board, alpha int, beta int, depth uint8, m *Move) (ProbeResult, int, *TTEntry) {
-	entryIndex := b.zobrist % TT.count
-	entry := &TT.entries[entryIndex]
+func (tt *TranspositionTable) Probe(b *Board, alpha int, beta int, depth uint8, m *Move) (ProbeResult, int, *TTEntry) {
+	cluster := tt.clusterFor(b.zobrist)
+	key := uint16(b.zobrist)
+
+	for i := range cluster.entries {
+		entry := &cluster.entries[i]
+		if !entry.isOccupied() || entry.key != key {
+			continue
+		}
 
-	if entry.hash == b.zobrist {
 		// Update age on access
-		entry.age = TT.age
+		entry.ageBound = tt.age<<TT_AGE_SHIFT | (entry.ageBound & (TT_OCCUPIED | TT_BOUND_MASK))
 
 		// Get the PV-move
-		*m = entry.bestMove
+		*m = b.UnpackMove(entry.bestMove)
+		score := scoreFromTT(entry.score)
 		if entry.depth >= depth {
-			score := int(entry.score)
-			if entry.bd == LOWER && score >= beta {
+			bd := entry.getBound()
+			if bd == LOWER && score >= beta {
 				return CUTOFF, beta, entry
 			}
 
-			if entry.bd == UPPER && score <= alpha {
+			if bd == UPPER && score <= alpha {
 				return CUTOFF, alpha, entry
 			}
 
-			if entry.bd == EXACT {
+			if bd == EXACT {
 				return CUTOFF, score, entry
 			}
 		}
 
-		return FAIL, int(entry.score), entry
+		return FAIL, score, entry
 	}
 
 	return NULL, 0, &TTEntry{}
 }
 
 // Increment age counter periodically
-func IncrementTTAge() {
-	TT.age++
+func (tt *TranspositionTable) IncrementAge() {
+	tt.age = (tt.age + 1) % TT_AGE_CYCLE
+}
+
+// Estimates how full the table is in permille by sampling the first 1000
+// entries and counting those written during the current search.
+func (tt *TranspositionTable) HashFull() int {
+	used := 0
+	sampled := 0
+	for i := 0; i < len(tt.clusters) && sampled < 1000; i++ {
+		for j := 0; j < TT_CLUSTER_SIZE && sampled < 1000; j++ {
+			entry := &tt.clusters[i].entries[j]
+			if entry.isOccupied() && entry.getAge() == tt.age {
+				used++
+			}
+			sampled++
+		}
+	}
+	return used * 1000 / Max(sampled, 1)
 }
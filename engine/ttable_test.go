@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"math"
+	"testing"
+	"unsafe"
+)
+
+func TestClusterSize(t *testing.T) {
+	if size := unsafe.Sizeof(TTCluster{}); size != 32 {
+		t.Errorf("TestClusterSize: got %d bytes, wanted %d", size, 32)
+	}
+}
+
+func TestTTUsesAllMemory(t *testing.T) {
+	// 3MB is not a power-of-two number of clusters, but all of it should be used
+	InitializeTT(3)
+	want := u64(3 * 1024 * 1024 / 32)
+	if TT.count != want {
+		t.Errorf("TestTTUsesAllMemory: got %d clusters, wanted %d", TT.count, want)
+	}
+
+	// Multiply-shift indexing should reach the top of the table
+	maxIdx := u64(0)
+	for i := 0; i < 100000; i++ {
+		idx := TT.index(u64(uint64(i) * 0x9E3779B97F4A7C15))
+		if idx > maxIdx {
+			maxIdx = idx
+		}
+	}
+	if maxIdx < TT.count*99/100 {
+		t.Errorf("TestTTUsesAllMemory: highest cluster reached was %d of %d", maxIdx, TT.count)
+	}
+}
+
+func TestTTScoreEncoding(t *testing.T) {
+	scores := []int{0, 1, -1, 250, -3000, TT_MAX_SCORE, -TT_MAX_SCORE, WIN_VAL, -WIN_VAL, WIN_VAL - 7, -WIN_VAL + 12, WIN_VAL - 150, -WIN_VAL + 150}
+	for _, score := range scores {
+		got := scoreFromTT(scoreToTT(score))
+		if got != score {
+			t.Errorf("TestTTScoreEncoding: got %d, wanted %d", got, score)
+		}
+	}
+
+	// Large non-mate scores are clamped
+	if got := scoreFromTT(scoreToTT(50000)); got != TT_MAX_SCORE {
+		t.Errorf("TestTTScoreEncoding (clamp): got %d, wanted %d", got, TT_MAX_SCORE)
+	}
+
+	// Scores beyond a mate still fit in 16 bits with the right sign
+	bounds := []struct {
+		score int
+		want  int16
+	}{
+		{WIN_VAL + math.MaxInt16 - TT_MATE_SCORE, math.MaxInt16},
+		{WIN_VAL + TT_MATE_ZONE, math.MaxInt16},
+		{-WIN_VAL - TT_MATE_ZONE, math.MinInt16},
+	}
+	for _, test := range bounds {
+		if got := scoreToTT(test.score); got != test.want {
+			t.Errorf("TestTTScoreEncoding (%d): got %d, wanted %d", test.score, got, test.want)
+		}
+	}
+}
+
+func TestTTStoreAndProbe(t *testing.T) {
+	InitializeTT(1)
+	b := Board{}
+	b.InitFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+
+	best := FromUCI("e2a6", &b)
+	StoreEntry(&b, 123, EXACT, best, 7, 45)
+
+	ttMove := Move{}
+	result, score, entry := ProbeTT(&b, -WIN_VAL, WIN_VAL, 5, &ttMove)
+	if result != CUTOFF || score != 123 {
+		t.Errorf("TestTTStoreAndProbe (cutoff): got result %d score %d, wanted %d score %d", result, score, CUTOFF, 123)
+	}
+	if ttMove.ToUCI() != "e2a6" || !ttMove.IsCapture() {
+		t.Errorf("TestTTStoreAndProbe (move): got %s, wanted %s", ttMove, "e2a6")
+	}
+	if entry.staticEval != 45 || entry.getBound() != EXACT {
+		t.Errorf("TestTTStoreAndProbe (entry): got eval %d bound %d", entry.staticEval, entry.getBound())
+	}
+
+	result, _, _ = ProbeTT(&b, -WIN_VAL, WIN_VAL, 8, &ttMove)
+	if result != FAIL {
+		t.Errorf("TestTTStoreAndProbe (too shallow): got %d, wanted %d", result, FAIL)
+	}
+
+	// A search without a best move should keep the previous one
+	StoreEntry(&b, -40, UPPER, Move{}, 9, 45)
+	ProbeTT(&b, -WIN_VAL, WIN_VAL, 0, &ttMove)
+	if ttMove.ToUCI() != "e2a6" {
+		t.Errorf("TestTTStoreAndProbe (keep move): got %s, wanted %s", ttMove, "e2a6")
+	}
+
+	b.MakeMove(best)
+	result, _, _ = ProbeTT(&b, -WIN_VAL, WIN_VAL, 0, &ttMove)
+	if result != NULL {
+		t.Errorf("TestTTStoreAndProbe (miss): got %d, wanted %d", result, NULL)
+	}
+}
+
+func TestTTReplacement(t *testing.T) {
+	InitializeTT(1)
+	cluster := &TT.clusters[0]
+	for i := range cluster.entries {
+		cluster.entries[i] = TTEntry{key: uint16(i + 1), depth: 10, ageBound: TT_OCCUPIED}
+	}
+
+	// An older but deeper entry should be replaced before newer shallow ones
+	cluster.entries[1].depth = 12
+	for i := 0; i < 3; i++ {
+		IncrementTTAge()
+	}
+	cluster.entries[0].ageBound = TT.age<<TT_AGE_SHIFT | TT_OCCUPIED
+	cluster.entries[2].ageBound = TT.age<<TT_AGE_SHIFT | TT_OCCUPIED
+
+	b := Board{}
+	b.InitStartPos()
+	b.zobrist = 0xABCD // Maps to the first cluster
+	StoreEntry(&b, 10, LOWER, Move{}, 1, 0)
+
+	if cluster.entries[1].key != 0xABCD {
+		t.Errorf("TestTTReplacement: entry with key %d was replaced instead of the stale one", cluster.entries[1].key)
+	}
+}
+
+func TestPackMove(t *testing.T) {
+	fens := []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"7k/8/8/8/pPp5/8/8/7K b - b3 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+	}
+
+	for _, fen := range fens {
+		b := Board{}
+		b.InitFEN(fen)
+		for _, m := range b.GenerateLegalMoves() {
+			got := b.UnpackMove(m.Pack())
+			if got != m {
+				t.Errorf("TestPackMove (%s): got %+v, wanted %+v", m, got, m)
+			}
+		}
+	}
+}
+
+func TestIsLegalRejectsBadGeometry(t *testing.T) {
+	b := Board{}
+	b.InitStartPos()
+	b.MakeMoveFromUCI("e2e4")
+	b.MakeMoveFromUCI("e7e5")
+
+	// Garbage moves that a TT key collision could produce
+	bad := []Move{
+		{from: G1, to: G3, piece: W_N, colorMoved: WHITE, captured: EMPTY, movetype: QUIET},
+		{from: F1, to: F3, piece: W_B, colorMoved: WHITE, captured: EMPTY, movetype: QUIET},
+		{from: D1, to: E3, piece: W_Q, colorMoved: WHITE, captured: EMPTY, movetype: QUIET},
+		{from: E1, to: E3, piece: W_K, colorMoved: WHITE, captured: EMPTY, movetype: QUIET},
+		{from: D2, to: E3, piece: W_P, colorMoved: WHITE, captured: EMPTY, movetype: QUIET},
+		{from: D2, to: D5, piece: W_P, colorMoved: WHITE, captured: EMPTY, movetype: QUIET},
+	}
+
+	for _, m := range bad {
+		if b.IsLegal(m) {
+			t.Errorf("TestIsLegalRejectsBadGeometry: %s was accepted", m)
+		}
+	}
+}
+
+func TestHashFull(t *testing.T) {
+	InitializeTT(1)
+	if got := HashFull(); got != 0 {
+		t.Errorf("TestHashFull (empty): got %d, wanted %d", got, 0)
+	}
+
+	for i := 0; i < 500; i++ {
+		entry := &TT.clusters[i/TT_CLUSTER_SIZE].entries[i%TT_CLUSTER_SIZE]
+		entry.ageBound = TT.age<<TT_AGE_SHIFT | TT_OCCUPIED
+	}
+	if got := HashFull(); got != 500 {
+		t.Errorf("TestHashFull (half): got %d, wanted %d", got, 500)
+	}
+
+	// Entries from previous searches don't count
+	IncrementTTAge()
+	if got := HashFull(); got != 0 {
+		t.Errorf("TestHashFull (new search): got %d, wanted %d", got, 0)
+	}
+}
@@ -35,7 +35,11 @@ type TunableParameters struct {
 	TM_NODE_COUNT_CONSTANT      int
 }
 
-var Params = TunableParameters{
+// Parameters of the default engine
+var Params = DEFAULT_PARAMS
+
+// Starting point for the parameters of new engines
+var DEFAULT_PARAMS = TunableParameters{
 	ASPIRATION_WINDOW_SIZE:   25,
 	RFP_MULT:                 132,
 	RFP_MAX_DEPTH:            11,
@@ -1,7 +1,7 @@
 package engine
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"reflect"
@@ -9,44 +9,87 @@ import (
 	"strings"
 )
 
+const VERSION = "v3.3.0"
+const AUTHOR = "Saigautam Bonam"
+
+// Default and maximum size of the Hash option in MB
+const DEFAULT_HASH = 256
+const MAX_HASH = 4096
+
 type UCIManager struct {
 	Version                 string
 	Author                  string
-	SearchThread            Searcher
+	Engine                  *Engine
+	SearchThread            *Searcher
 	HashSize                int64
+	MoveOverhead            int64
+	NodesTime               int64
 	PonderingEnabled        bool
-	PonderHit               bool
+	ShowWDL                 bool
+	LimitStrength           bool
+	Elo                     int
+	SkillLevel              int
+	Contempt                int
+	RandomDrawScore         bool
+	DebugLogFile            string
+	JSONOutput              bool // Print search iterations as JSON objects
 	TunableParams           *TunableParameters
 	ExposeTunableParameters bool
+	controller              SearchController
+	ponderHit               context.CancelFunc // Ends the ponder phase of the current search
 }
 
 func (uci *UCIManager) Initialize() {
-	fmt.Println("initializing...")
+	fmt.Fprintln(Output, "initializing...")
 
 	// Set default UCI options
-	uci.HashSize = int64(256)
+	uci.HashSize = DEFAULT_HASH
+	uci.MoveOverhead = DEFAULT_MOVE_OVERHEAD
+	uci.NodesTime = 0
 	uci.PonderingEnabled = false
-	uci.TunableParams = &Params
-	uci.Version = "v3.3.0"
-	uci.Author = "Saigautam Bonam"
-	uci.SearchThread = Searcher{}
+	uci.ShowWDL = false
+	uci.LimitStrength = false
+	uci.Elo = SKILL_MAX_ELO
+	uci.SkillLevel = MAX_SKILL_LEVEL
+	uci.Contempt = 0
+	uci.RandomDrawScore = false
+	uci.DebugLogFile = "<empty>"
+	uci.Version = VERSION
+	uci.Author = AUTHOR
 
 	// For SPSA tuning, ExposeTunableParameters needs to be true
 	uci.ExposeTunableParameters = false
 
 	// Initialize
 	InitializeEverythingExceptTTable()
-	InitializeTT(int(uci.HashSize))
+	if uci.Engine == nil {
+		uci.Engine = DefaultEngine
+	}
+	uci.Engine.TT.Resize(int(uci.HashSize))
+	uci.Engine.Timer.SetMoveOverhead(uci.MoveOverhead)
+	uci.TunableParams = uci.Engine.Params
+	uci.SearchThread = uci.Engine.MainSearcher()
 	uci.SearchThread.Position = NewBoard()
+	uci.SearchThread.Position.InitStartPos()
+	uci.SearchThread.Info.JSON = uci.JSONOutput
 
-	fmt.Println("done, ready for UCI commands")
+	fmt.Fprintln(Output, "done, ready for UCI commands")
 }
 
 func (uci *UCIManager) UCI() {
-	fmt.Printf("id name Maelstrom %s\n", uci.Version)
-	fmt.Printf("id author %s\n", uci.Author)
-	fmt.Printf("option name Hash type spin default %d min 1 max 4096\n", uci.HashSize)
-	fmt.Printf("option name Ponder type check default %t\n", uci.PonderingEnabled)
+	fmt.Fprintf(Output, "id name Maelstrom %s\n", uci.Version)
+	fmt.Fprintf(Output, "id author %s\n", uci.Author)
+	fmt.Fprintf(Output, "option name Hash type spin default %d min 1 max %d\n", uci.HashSize, MAX_HASH)
+	fmt.Fprintf(Output, "option name Move Overhead type spin default %d min 0 max %d\n", uci.MoveOverhead, MAX_MOVE_OVERHEAD)
+	fmt.Fprintf(Output, "option name nodestime type spin default %d min 0 max %d\n", uci.NodesTime, MAX_NODES_TIME)
+	fmt.Fprintf(Output, "option name Ponder type check default %t\n", uci.PonderingEnabled)
+	fmt.Fprintf(Output, "option name UCI_ShowWDL type check default %t\n", uci.ShowWDL)
+	fmt.Fprintf(Output, "option name UCI_LimitStrength type check default %t\n", uci.LimitStrength)
+	fmt.Fprintf(Output, "option name UCI_Elo type spin default %d min %d max %d\n", uci.Elo, SKILL_MIN_ELO, SKILL_MAX_ELO)
+	fmt.Fprintf(Output, "option name Skill Level type spin default %d min 0 max %d\n", uci.SkillLevel, MAX_SKILL_LEVEL)
+	fmt.Fprintf(Output, "option name Contempt type spin default %d min %d max %d\n", uci.Contempt, -MAX_CONTEMPT, MAX_CONTEMPT)
+	fmt.Fprintf(Output, "option name Random Draw Score type check default %t\n", uci.RandomDrawScore)
+	fmt.Fprintf(Output, "option name Debug Log File type string default %s\n", uci.DebugLogFile)
 
 	if uci.ExposeTunableParameters {
 		val := reflect.ValueOf(*uci.TunableParams)
@@ -55,138 +98,160 @@ func (uci *UCIManager) UCI() {
 		for i := 0; i < val.NumField(); i++ {
 			name := typ.Field(i).Name
 			defaultVal := val.Field(i).Int()
-			fmt.Printf("option name %s type spin default %d min 0 max 10000\n", name, defaultVal)
+			fmt.Fprintf(Output, "option name %s type spin default %d min 0 max 10000\n", name, defaultVal)
 		}
 	}
-	fmt.Println("uciok")
+	fmt.Fprintln(Output, "uciok")
 }
 
 func (uci *UCIManager) IsReady() {
-	fmt.Println("readyok")
+	fmt.Fprintln(Output, "readyok")
 }
 
 func (uci *UCIManager) UCINewGame() {
+	uci.controller.StopAndWait()
 	uci.SearchThread.Position = NewBoard()
-	uci.SearchThread.ClearHistory()
-	uci.SearchThread.ClearContHist()
-	uci.SearchThread.ClearKillers()
-	uci.SearchThread.ClearCounters()
-	ClearTT()
+	uci.SearchThread.Position.InitStartPos()
+	uci.Engine.NewGame()
 }
 
 func (uci *UCIManager) Stop() {
-	Timer.Stop = true
+	uci.controller.Stop()
 }
 
 func (uci *UCIManager) PonderHitUpdate() {
-	uci.PonderHit = true
-	Timer.Stop = true
+	if uci.ponderHit != nil {
+		uci.ponderHit()
+	}
 }
 
 func (uci *UCIManager) Position(position string) {
-	Timer.Stop = true
-	*uci.SearchThread.Position = uci.processPosition(position)
+	uci.controller.StopAndWait()
+	b, err := uci.processPosition(position)
+	if err != nil {
+		fmt.Fprintf(Output, "info string %v\n", err)
+		return
+	}
+	*uci.SearchThread.Position = b
 }
 
 func (uci *UCIManager) Go(options string) {
 	uci.processGo(options)
 }
 
-func (uci *UCIManager) processPosition(command string) Board {
+// Parses `position [startpos | fen <fen>] [moves <move>...]`. Four-field FENs
+// without move counters are accepted as well.
+func (uci *UCIManager) processPosition(command string) (Board, error) {
 	b := Board{}
-	words := strings.Split(command, " ")
-	length := len(words)
-	mvStart := 2
+	words := strings.Fields(command)
+	if len(words) < 2 {
+		return b, fmt.Errorf("position: missing startpos or fen")
+	}
 
-	if words[1] != "fen" {
-		b.InitStartPos()
-		if length == 2 {
-			return b
-		}
-		if words[2] == "moves" {
-			mvStart = 3
+	mvStart := len(words)
+	for i, word := range words {
+		if word == "moves" {
+			mvStart = i
+			break
 		}
-	} else {
-		fen := words[2] + " " + words[3] + " " + words[4]
-		fen += " " + words[5] + " " + words[6] + " " + words[7]
-		b.InitFEN(fen)
-		if length == 8 {
-			return b
+	}
+
+	switch words[1] {
+	case "startpos":
+		if mvStart != 2 && len(words) > 2 {
+			return b, fmt.Errorf("position: unexpected %q after startpos", words[2])
 		}
-		mvStart = 8
-		if length > 8 && words[8] == "moves" {
-			mvStart = 9
+		b.InitStartPos()
+	case "fen":
+		parsed, err := ParseFEN(strings.Join(words[2:mvStart], " "))
+		if err != nil {
+			return b, fmt.Errorf("position: %w", err)
 		}
+		b = *parsed
+	default:
+		return b, fmt.Errorf("position: expected startpos or fen, got %q", words[1])
 	}
 
-	for i := mvStart; i < length; i++ {
-		b.MakeMoveFromUCI(words[i])
+	for i := mvStart + 1; i < len(words); i++ {
+		mv, err := LegalMoveFromUCI(words[i], &b)
+		if err != nil {
+			return Board{}, fmt.Errorf("position: move %d: %v", i-mvStart, err)
+		}
+		b.MakeMove(mv)
 	}
 
-	return b
+	return b, nil
 }
 
 func (uci *UCIManager) processGo(command string) {
-	words := strings.Split(command, " ")
+	words := strings.Fields(command)
 
 	var wtime, btime, winc, binc, depth, movetime, nodes, movestogo int64
 	var infinite bool
 	var shouldPonder bool
 
-	for i := 0; i < len(words); i++ {
+	// Limits that take a numeric argument
+	limits := map[string]*int64{
+		"depth":     &depth,
+		"nodes":     &nodes,
+		"movetime":  &movetime,
+		"wtime":     &wtime,
+		"btime":     &btime,
+		"winc":      &winc,
+		"binc":      &binc,
+		"movestogo": &movestogo,
+	}
+
+	// A bad limit is reported and left out, the GUI still gets a bestmove
+	for i := 1; i < len(words); i++ {
+		if limit, ok := limits[words[i]]; ok {
+			if i+1 >= len(words) {
+				fmt.Fprintf(Output, "info string go: missing value for %s\n", words[i])
+				continue
+			}
+			value, err := strconv.ParseInt(words[i+1], 10, 64)
+			if err != nil {
+				fmt.Fprintf(Output, "info string go: invalid value %q for %s\n", words[i+1], words[i])
+			} else {
+				*limit = value
+			}
+			i++
+			continue
+		}
+
 		switch words[i] {
 		case "infinite":
 			infinite = true
-		case "depth":
-			if i+1 < len(words) {
-				depth, _ = strconv.ParseInt(words[i+1], 10, 64)
-				i++
-			}
-		case "nodes":
-			if i+1 < len(words) {
-				nodes, _ = strconv.ParseInt(words[i+1], 10, 64)
-				i++
-			}
-		case "movetime":
-			if i+1 < len(words) {
-				movetime, _ = strconv.ParseInt(words[i+1], 10, 64)
-				i++
-			}
-		case "wtime":
-			if i+1 < len(words) {
-				wtime, _ = strconv.ParseInt(words[i+1], 10, 64)
-				i++
-			}
-		case "btime":
-			if i+1 < len(words) {
-				btime, _ = strconv.ParseInt(words[i+1], 10, 64)
-				i++
-			}
-		case "winc":
-			if i+1 < len(words) {
-				winc, _ = strconv.ParseInt(words[i+1], 10, 64)
-				i++
-			}
-		case "binc":
-			if i+1 < len(words) {
-				binc, _ = strconv.ParseInt(words[i+1], 10, 64)
-				i++
-			}
-		case "movestogo":
-			if i+1 < len(words) {
-				movestogo, _ = strconv.ParseInt(words[i+1], 10, 64)
-				i++
-			}
 		case "ponder":
 			shouldPonder = true
 			infinite = true
+		case "searchmoves":
+			// Takes the rest of the command
+			fmt.Fprintln(Output, "info string go: ignoring unsupported searchmoves")
+			i = len(words)
+		default:
+			fmt.Fprintf(Output, "info string go: ignoring unsupported %q\n", words[i])
 		}
 	}
 
-	Timer.Calculate(uci.SearchThread.Position.turn, wtime, btime, winc, binc, movestogo, depth, nodes, movetime, infinite)
+	// The previous search must be done before we touch the timer
+	uci.controller.StopAndWait()
+	uci.Engine.Timer.Calculate(uci.SearchThread.Position.turn, wtime, btime, winc, binc, movestogo, depth, nodes, movetime, infinite)
+
+	ponder := uci.PonderingEnabled && shouldPonder && uci.SearchThread.Info.PonderMove.to != uci.SearchThread.Info.PonderMove.from
+	var ponderCtx context.Context
+	var endPonder context.CancelFunc
+	if ponder {
+		ponderCtx, endPonder = context.WithCancel(context.Background())
+	}
+	uci.ponderHit = endPonder
 
 	// Start search in a goroutine
-	go func() {
+	uci.controller.Start(func(ctx context.Context) {
+		timer := uci.Engine.Timer
+		timer.StopOn(ctx.Done())
+		defer timer.StopOn(nil)
+
 		// Ponder workflow:
 		// Server sends go ponder ...
 		// We start ponder search until either the server sends:
@@ -194,21 +259,23 @@ func (uci *UCIManager) processGo(command string) {
 		// - stop ...
 		// When ponderhit is sent, switch to normal search with reduced movetime
 		// When stop is sent, stop search, print bestmove and return
-		if uci.PonderingEnabled && shouldPonder && uci.SearchThread.Info.PonderMove.to != uci.SearchThread.Info.PonderMove.from {
-			// Start ponder
+		if ponder {
+			// Start ponder, which ends on ponderhit as well as on stop
+			defer endPonder()
+			defer context.AfterFunc(ctx, endPonder)()
+			timer.StopOn(ponderCtx.Done())
 			uci.SearchThread.Info.IsPondering = true
 			uci.SearchThread.SearchPosition()
 			uci.SearchThread.Info.IsPondering = false
+			timer.StopOn(ctx.Done())
 
-			if uci.PonderHit {
-				Timer.Calculate(uci.SearchThread.Position.turn, wtime, btime, winc, binc, movestogo, depth, nodes, movetime, false)
+			if ctx.Err() == nil && ponderCtx.Err() != nil {
+				timer.Calculate(uci.SearchThread.Position.turn, wtime, btime, winc, binc, movestogo, depth, nodes, movetime, false)
 				// Reduce time spent since we got a ponderhit
-				Timer.softLimit /= 2
-				Timer.hardLimit /= 2
-
-				uci.PonderHit = false
+				timer.softLimit /= 2
+				timer.hardLimit /= 2
 			} else {
-				fmt.Println("bestmove")
+				fmt.Fprintln(Output, "bestmove")
 				return
 			}
 		}
@@ -216,80 +283,194 @@ func (uci *UCIManager) processGo(command string) {
 		bestMove := uci.SearchThread.SearchPosition()
 
 		if uci.PonderingEnabled && uci.SearchThread.Info.PonderMove.to != uci.SearchThread.Info.PonderMove.from {
-			fmt.Println("bestmove " + bestMove.ToUCI() + " ponder " + uci.SearchThread.Info.PonderMove.ToUCI())
-			return
+			fmt.Fprintln(Output, "bestmove "+bestMove.ToUCI()+" ponder "+uci.SearchThread.Info.PonderMove.ToUCI())
+		} else {
+			fmt.Fprintln(Output, "bestmove "+bestMove.ToUCI())
 		}
 
-		fmt.Println("bestmove " + bestMove.ToUCI())
-	}()
+		timer.FinishSearch(&uci.SearchThread.Info)
+	})
 }
 
 func (uci *UCIManager) SetOption(option string) {
-	words := strings.Split(option, " ")
-	if len(words) >= 5 && words[1] == "name" && words[3] == "value" {
-		paramName := words[2]
-		if paramName == "Hash" {
-			uci.HashSize, _ = strconv.ParseInt(words[4], 10, 64)
-			InitializeTT(int(uci.HashSize))
-			return
-		} else if paramName == "Ponder" {
-			ponder, _ := strconv.ParseBool(words[4])
-			uci.PonderingEnabled = ponder
-			uci.SearchThread.Info.PonderingEnabled = ponder
+	uci.controller.StopAndWait()
+	words := strings.Fields(option)
+
+	// Option names may contain spaces, e.g. "Skill Level"
+	valueIdx := 0
+	for i, word := range words {
+		if word == "value" {
+			valueIdx = i
+			break
+		}
+	}
+
+	if len(words) < 4 || words[1] != "name" || valueIdx <= 2 {
+		fmt.Fprintln(Output, "info string setoption: expected setoption name <id> value <x>")
+		return
+	}
+
+	paramName := strings.Join(words[2:valueIdx], " ")
+	value := strings.Join(words[valueIdx+1:], " ")
+
+	switch paramName {
+	case "Debug Log File":
+		// Paths may contain spaces, so take the value as written
+		_, path, _ := strings.Cut(option, " value")
+		if err := Output.SetLogFile(strings.TrimSpace(path)); err != nil {
+			fmt.Fprintf(Output, "info string setoption: cannot open debug log: %v\n", err)
 			return
 		}
-		paramValue, err := strconv.Atoi(words[4])
+		uci.DebugLogFile = strings.TrimSpace(path)
+		if uci.DebugLogFile == "" {
+			uci.DebugLogFile = "<empty>"
+		}
+		return
+	case "Ponder", "UCI_ShowWDL", "Random Draw Score", "UCI_LimitStrength":
+		flag, err := strconv.ParseBool(value)
 		if err != nil {
-			fmt.Println("info string invalid value")
+			fmt.Fprintf(Output, "info string setoption: invalid value %q for %s\n", value, paramName)
 			return
 		}
+		switch paramName {
+		case "Ponder":
+			uci.PonderingEnabled = flag
+			uci.SearchThread.Info.PonderingEnabled = flag
+		case "UCI_ShowWDL":
+			uci.ShowWDL = flag
+			uci.SearchThread.Info.ShowWDL = flag
+		case "Random Draw Score":
+			uci.RandomDrawScore = flag
+			uci.SearchThread.Info.RandomDrawScore = flag
+		case "UCI_LimitStrength":
+			uci.LimitStrength = flag
+			uci.updateSkill()
+		}
+		return
+	}
+
+	paramValue, err := strconv.Atoi(value)
+	if err != nil {
+		fmt.Fprintf(Output, "info string setoption: invalid value %q for %s\n", value, paramName)
+		return
+	}
 
+	switch paramName {
+	case "Hash":
+		uci.HashSize = int64(Clamp(paramValue, 1, MAX_HASH))
+		uci.Engine.TT.Resize(int(uci.HashSize))
+	case "nodestime":
+		uci.NodesTime = int64(Clamp(paramValue, 0, MAX_NODES_TIME))
+		uci.Engine.Timer.SetNodesTime(uci.NodesTime)
+	case "Move Overhead":
+		uci.MoveOverhead = int64(Clamp(paramValue, 0, MAX_MOVE_OVERHEAD))
+		uci.Engine.Timer.SetMoveOverhead(uci.MoveOverhead)
+	case "UCI_Elo":
+		uci.Elo = Clamp(paramValue, SKILL_MIN_ELO, SKILL_MAX_ELO)
+		uci.updateSkill()
+	case "Skill Level":
+		uci.SkillLevel = Clamp(paramValue, 0, MAX_SKILL_LEVEL)
+		uci.updateSkill()
+	case "Contempt":
+		// The option is in (normalized) centipawns
+		uci.Contempt = Clamp(paramValue, -MAX_CONTEMPT, MAX_CONTEMPT)
+		uci.SearchThread.Info.Contempt = uci.Contempt * NORMALIZE_TO_PAWN_VALUE / 100
+	default:
 		pVal := reflect.ValueOf(uci.TunableParams).Elem()
 		pField := pVal.FieldByName(paramName)
-		if pField.IsValid() && pField.CanSet() && pField.Kind() == reflect.Int {
-			pField.SetInt(int64(paramValue))
+		if !pField.IsValid() || !pField.CanSet() || pField.Kind() != reflect.Int {
+			fmt.Fprintf(Output, "info string setoption: unknown option %q\n", paramName)
+			return
 		}
+		pField.SetInt(int64(paramValue))
 	}
+}
 
+// UCI_LimitStrength takes precedence over Skill Level
+func (uci *UCIManager) updateSkill() {
+	if uci.LimitStrength {
+		uci.SearchThread.Skill.SetLevel(SkillLevelFromElo(uci.Elo))
+	} else {
+		uci.SearchThread.Skill.SetLevel(float64(uci.SkillLevel))
+	}
 }
 
 func (uci *UCIManager) DebugPosition() {
-	uci.SearchThread.Position.PrintFromBitBoards()
+	uci.controller.StopAndWait()
+	uci.SearchThread.Position.PrintFromBitBoards(Output)
 }
 
 func (uci *UCIManager) StaticEvaluate() {
-	eval := EvaluateNNUE(uci.SearchThread.Position)
-	fmt.Println(eval)
+	uci.controller.StopAndWait()
+	eval := uci.Engine.NNUE.Evaluate(uci.SearchThread.Position)
+	fmt.Fprintln(Output, eval)
 }
 
-func (uci *UCIManager) UciLoop() {
-	scanner := bufio.NewScanner(os.Stdin)
-
-	for scanner.Scan() {
-		command := scanner.Text()
-
-		if command == "uci" {
-			uci.UCI()
-		} else if command == "isready" {
-			uci.IsReady()
-		} else if command == "ucinewgame" {
-			uci.UCINewGame()
-		} else if command == "quit" {
-			os.Exit(0)
-		} else if command == "stop" {
-			uci.Stop()
-		} else if command == "ponderhit" {
-			uci.PonderHitUpdate()
-		} else if strings.Contains(command, "position") {
-			uci.Position(command)
-		} else if strings.Contains(command, "go") {
-			uci.Go(command)
-		} else if strings.Contains(command, "setoption") {
-			uci.SetOption(command)
-		} else if command == "d" {
-			uci.DebugPosition()
-		} else if command == "eval" {
-			uci.StaticEvaluate()
-		}
+// Searches the bench positions on a separate engine, see bench.go
+func (uci *UCIManager) Bench(args []string) {
+	uci.controller.StopAndWait()
+	depth, pseudoLegal, err := benchArgs(args)
+	if err != nil {
+		fmt.Fprintf(Output, "info string %v\n", err)
+		return
+	}
+	RunBench(depth, pseudoLegal, Output)
+}
+
+// Prints the search statistics collected since startup or the last
+// `stats reset`, see stats.go
+func (uci *UCIManager) Stats(args []string) {
+	uci.controller.StopAndWait()
+	if len(args) > 0 && args[0] == "reset" {
+		uci.SearchThread.Stats.Reset()
+		return
 	}
+	uci.SearchThread.Stats.Print(Output)
+}
+
+// Runs a single command, dispatching on its first token. Returns false on quit.
+func (uci *UCIManager) HandleCommand(command string) bool {
+	words := strings.Fields(command)
+	if len(words) == 0 {
+		return true
+	}
+
+	switch words[0] {
+	case "uci":
+		uci.UCI()
+	case "isready":
+		uci.IsReady()
+	case "ucinewgame":
+		uci.UCINewGame()
+	case "quit":
+		uci.controller.StopAndWait()
+		return false
+	case "stop":
+		uci.Stop()
+	case "ponderhit":
+		uci.PonderHitUpdate()
+	case "position":
+		uci.Position(command)
+	case "go":
+		uci.Go(command)
+	case "setoption":
+		uci.SetOption(command)
+	case "debug":
+		// Debug mode has no effect
+	case "d":
+		uci.DebugPosition()
+	case "eval":
+		uci.StaticEvaluate()
+	case "bench":
+		uci.Bench(words[1:])
+	case "stats":
+		uci.Stats(words[1:])
+	default:
+		fmt.Fprintf(Output, "info string unknown command %q\n", words[0])
+	}
+	return true
+}
+
+func (uci *UCIManager) UciLoop() {
+	commandLoop(os.Stdin, uci.HandleCommand)
 }
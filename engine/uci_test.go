@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Fires commands at the manager without waiting for searches to finish, the
+// way a GUI might. Run with -race to check the search lifecycle.
+func TestUCICommandStress(t *testing.T) {
+	uci := UCIManager{Engine: NewEngine(1)}
+	uci.Initialize()
+	uci.SetOption("setoption name Hash value 1")
+
+	commands := []func(){
+		func() { uci.Position("position startpos moves e2e4 e7e5") },
+		func() {
+			uci.Position("position fen r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3 moves f1b5")
+		},
+		func() { uci.Go("go infinite") },
+		func() { uci.Go("go depth 4") },
+		func() { uci.Go("go nodes 5000") },
+		func() { uci.Go("go wtime 100 btime 100 winc 10 binc 10") },
+		func() { uci.Go("go ponder wtime 1000 btime 1000") },
+		func() { uci.Stop() },
+		func() { uci.PonderHitUpdate() },
+		func() { uci.UCINewGame() },
+		func() { uci.SetOption("setoption name Ponder value true") },
+		func() { uci.SetOption("setoption name Contempt value 20") },
+		func() { uci.SetOption("setoption name Skill Level value 10") },
+		func() { uci.SetOption("setoption name Hash value 2") },
+		func() { time.Sleep(time.Millisecond) },
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		commands[rng.Intn(len(commands))]()
+	}
+
+	uci.Stop()
+	done := make(chan struct{})
+	go func() {
+		uci.controller.StopAndWait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("TestUCICommandStress: search did not stop")
+	}
+}
+
+func TestProcessPosition(t *testing.T) {
+	InitializeEverythingExceptTTable()
+	uci := UCIManager{}
+
+	valid := []struct {
+		command string
+		want    string
+	}{
+		{"position startpos", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"},
+		{"  position   startpos  moves  e2e4\te7e5 ", "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 1"},
+		{"position fen 8/8/4k3/8/8/3K4/8/8 b - -", "8/8/4k3/8/8/3K4/8/8 b - - 0 1"},
+		{"position fen 8/P7/4k3/8/8/3K4/8/8 w - - 0 1 moves a7a8q", "Q7/8/4k3/8/8/3K4/8/8 b - - 0 1"},
+	}
+	for _, test := range valid {
+		b, err := uci.processPosition(test.command)
+		if err != nil {
+			t.Errorf("TestProcessPosition (%q): %v", test.command, err)
+			continue
+		}
+		want := Board{}
+		want.InitFEN(test.want)
+		if b.zobrist != want.zobrist {
+			t.Errorf("TestProcessPosition (%q): wrong position", test.command)
+		}
+	}
+
+	invalid := []string{
+		"position",
+		"position somewhere",
+		"position startpos e2e4",
+		"position fen",
+		"position fen 8/8/8/8 w - - 0 1",
+		"position fen 8/8/4k3/8/8/3K4/8/8 x - - 0 1",
+		"position fen 8/8/4k3/8/8/3K4/8/9 w - - 0 1",
+		"position fen 8/8/8/8/8/3K4/8/8 w - - 0 1",
+		"position fen 8/8/4k3/8/8/3K4/8/8 w - - zero 1",
+		"position startpos moves e2e5",
+		"position startpos moves e2",
+		"position startpos moves e2e4 e2e4",
+	}
+	for _, command := range invalid {
+		if _, err := uci.processPosition(command); err == nil {
+			t.Errorf("TestProcessPosition (%q): expected an error", command)
+		}
+	}
+}
+
+func TestHandleCommand(t *testing.T) {
+	uci := UCIManager{Engine: NewEngine(1)}
+	uci.Initialize()
+	uci.SetOption("setoption name Hash value 1")
+
+	// A bad position keeps the previous one
+	uci.HandleCommand("position startpos moves g1f3")
+	before := uci.SearchThread.Position.zobrist
+	uci.HandleCommand("position startpos moves g1f3 g1f3")
+	if uci.SearchThread.Position.zobrist != before {
+		t.Errorf("TestHandleCommand (position): illegal move changed the position")
+	}
+
+	uci.HandleCommand("  setoption  name  Contempt  value  10 ")
+	if uci.Contempt != 10 {
+		t.Errorf("TestHandleCommand (setoption): got contempt %d, wanted %d", uci.Contempt, 10)
+	}
+	uci.HandleCommand("setoption name Contempt value lots")
+	if uci.Contempt != 10 {
+		t.Errorf("TestHandleCommand (setoption): invalid value changed contempt to %d", uci.Contempt)
+	}
+
+	// Only the first token selects the command
+	for _, command := range []string{"gorilla", "stop go", "", "   "} {
+		if !uci.HandleCommand(command) {
+			t.Errorf("TestHandleCommand (%q): should not quit", command)
+		}
+	}
+	uci.HandleCommand("go depth x")
+	if uci.HandleCommand("quit") {
+		t.Errorf("TestHandleCommand (quit): should quit")
+	}
+}
+
+func TestGoWithBadLimits(t *testing.T) {
+	out := bytes.Buffer{}
+	Output.mu.Lock()
+	stdout := Output.out
+	Output.out = &out
+	Output.mu.Unlock()
+	defer func() {
+		Output.mu.Lock()
+		Output.out = stdout
+		Output.mu.Unlock()
+	}()
+
+	uci := UCIManager{Engine: NewEngine(1)}
+	uci.Initialize()
+	uci.HandleCommand("position startpos")
+
+	// The GUI waits for a bestmove, so the limits that parse are still searched
+	for _, command := range []string{"go wtime abc btime 1000 depth 3", "go depth 2 nodes"} {
+		out.Reset()
+		uci.HandleCommand(command)
+		uci.controller.Wait()
+		Output.mu.Lock()
+		got := out.String()
+		Output.mu.Unlock()
+		if !strings.Contains(got, "info string go:") || !strings.Contains(got, "bestmove ") {
+			t.Errorf("TestGoWithBadLimits (%s): got %q", command, got)
+		}
+	}
+	if depth := uci.Engine.Timer.maxDepth; depth != 2 {
+		t.Errorf("TestGoWithBadLimits: got depth limit %d, wanted %d", depth, 2)
+	}
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"math"
+)
+
+// WIN/DRAW/LOSS MODEL
+//
+//	The expected outcome of a position depends on both the score and the amount of material left
+//	on the board: +1.50 in a queenless endgame wins far more often than +1.50 in the middlegame.
+//	Following modern engines we model the win probability as a logistic function of the score,
+//	    P(win) = 1 / (1 + exp((a - score) / b))
+//	where a and b are cubic polynomials of the (normalized) material count. P(loss) is obtained by
+//	mirroring the score and P(draw) is whatever is left. The coefficients are fit on self-play
+//	data using FitWDL (see `maelstrom fitwdl`).
+type WDLModel struct {
+	A [4]float64 // Polynomial coefficients for a, highest order first
+	B [4]float64 // Polynomial coefficients for b, highest order first
+}
+
+// Material bounds and reference point used to normalize the material count
+const WDL_MIN_MATERIAL = 17
+const WDL_MAX_MATERIAL = 78
+const WDL_REFERENCE_MATERIAL = 58
+
+// Fit with `maelstrom fitwdl -games 300 -nodes 4000 -seed 7` (39183 positions)
+var WDL_MODEL = WDLModel{
+	A: [4]float64{-3321.05, 9616.28, -9039.41, 2956.03},
+	B: [4]float64{-2560.60, 7135.38, -6266.66, 1915.59},
+}
+
+// SCORE NORMALIZATION
+//
+//	Internal scores have no fixed meaning, so before reporting them over UCI we rescale them such that
+//	100cp corresponds to a 50% win probability at the reference material count. This is the value of
+//	the WDL model's a parameter at WDL_REFERENCE_MATERIAL, and is printed by `maelstrom fitwdl`
+//	together with the model coefficients. Search itself always uses the raw scores.
+const NORMALIZE_TO_PAWN_VALUE = 212
+
+// Converts an internal score to normalized centipawns for UCI output. Mate
+// scores are left untouched.
+func NormalizeScore(score int) int {
+	if Abs(score) >= WIN_VAL-TT_MATE_ZONE {
+		return score
+	}
+	return score * 100 / NORMALIZE_TO_PAWN_VALUE
+}
+
+// Material count used by the WDL model: pawn = 1, minor = 3, rook = 5, queen = 9
+func WDLMaterial(b *Board) int {
+	material := PopCount(b.pieces[W_P] | b.pieces[B_P])
+	material += 3 * PopCount(b.pieces[W_N]|b.pieces[B_N]|b.pieces[W_B]|b.pieces[B_B])
+	material += 5 * PopCount(b.pieces[W_R]|b.pieces[B_R])
+	material += 9 * PopCount(b.pieces[W_Q]|b.pieces[B_Q])
+	return material
+}
+
+// Returns the logistic parameters a and b for the given material count
+func (w *WDLModel) Params(material int) (float64, float64) {
+	m := float64(Clamp(material, WDL_MIN_MATERIAL, WDL_MAX_MATERIAL)) / WDL_REFERENCE_MATERIAL
+	a := ((w.A[0]*m+w.A[1])*m+w.A[2])*m + w.A[3]
+	b := ((w.B[0]*m+w.B[1])*m+w.B[2])*m + w.B[3]
+	return a, b
+}
+
+// Probability (in [0, 1]) that the side with the given score wins
+func (w *WDLModel) WinProbability(score int, material int) float64 {
+	a, b := w.Params(material)
+	return 1 / (1 + math.Exp((a-float64(score))/b))
+}
+
+// Converts a search score into win/draw/loss probabilities in permille
+func (w *WDLModel) WDL(score int, material int) (int, int, int) {
+	if score >= WIN_VAL-TT_MATE_ZONE {
+		return 1000, 0, 0
+	}
+	if score <= -WIN_VAL+TT_MATE_ZONE {
+		return 0, 0, 1000
+	}
+
+	win := int(0.5 + 1000*w.WinProbability(score, material))
+	loss := int(0.5 + 1000*w.WinProbability(-score, material))
+	return win, 1000 - win - loss, loss
+}
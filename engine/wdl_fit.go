@@ -0,0 +1,278 @@
+package engine
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A single training sample for the WDL model. Score and result are from
+// White's perspective, with result being 1 (win), 0.5 (draw) or 0 (loss).
+type WDLSample struct {
+	Score    int
+	Result   float64
+	Material int
+}
+
+var WDL_PIECE_MATERIAL = map[rune]int{
+	'P': 1, 'N': 3, 'B': 3, 'R': 5, 'Q': 9,
+	'p': 1, 'n': 3, 'b': 3, 'r': 5, 'q': 9,
+}
+
+func materialFromFEN(fen string) int {
+	material := 0
+	for _, c := range strings.Fields(fen)[0] {
+		material += WDL_PIECE_MATERIAL[c]
+	}
+	return material
+}
+
+// Reads samples in the `fen | score | result` format written by
+// GenerateSelfPlayData.
+func ReadWDLSamples(r io.Reader) ([]WDLSample, error) {
+	samples := []WDLSample{}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		parts := strings.Split(line, "|")
+		if len(parts) != 3 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("line %d: expected `fen | score | result`", lineNum)
+		}
+
+		if _, err := ParseFEN(parts[0]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		score, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid score: %w", lineNum, err)
+		}
+
+		result, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+		if err != nil || (result != 0 && result != 0.5 && result != 1) {
+			return nil, fmt.Errorf("line %d: invalid result %q", lineNum, strings.TrimSpace(parts[2]))
+		}
+
+		samples = append(samples, WDLSample{
+			Score:    score,
+			Result:   result,
+			Material: materialFromFEN(parts[0]),
+		})
+	}
+
+	return samples, scanner.Err()
+}
+
+// Negative log-likelihood of the observed results under the given model.
+// Samples are grouped by material so a and b are computed once per group.
+func wdlLoss(model *WDLModel, groups map[int][]WDLSample) float64 {
+	const eps = 1e-9
+	loss := 0.0
+	count := 0
+	for material, samples := range groups {
+		a, b := model.Params(material)
+		if b <= 0 {
+			return math.Inf(1)
+		}
+
+		for _, sample := range samples {
+			win := 1 / (1 + math.Exp((a-float64(sample.Score))/b))
+			lose := 1 / (1 + math.Exp((a+float64(sample.Score))/b))
+
+			p := 1 - win - lose
+			if sample.Result == 1 {
+				p = win
+			} else if sample.Result == 0 {
+				p = lose
+			}
+			loss -= math.Log(math.Max(p, eps))
+		}
+		count += len(samples)
+	}
+	return loss / float64(count)
+}
+
+// Groups samples by their clamped material count
+func groupByMaterial(samples []WDLSample) map[int][]WDLSample {
+	groups := map[int][]WDLSample{}
+	for _, sample := range samples {
+		material := Clamp(sample.Material, WDL_MIN_MATERIAL, WDL_MAX_MATERIAL)
+		groups[material] = append(groups[material], sample)
+	}
+	return groups
+}
+
+// Fits the WDL model to the samples by maximum likelihood. A material-
+// independent model is fit first and then used as the starting point for
+// the full cubic model.
+func FitWDL(samples []WDLSample) WDLModel {
+	groups := groupByMaterial(samples)
+
+	constant := func(x []float64) WDLModel {
+		return WDLModel{A: [4]float64{0, 0, 0, x[0]}, B: [4]float64{0, 0, 0, x[1]}}
+	}
+	x := nelderMead(func(x []float64) float64 {
+		model := constant(x)
+		return wdlLoss(&model, groups)
+	}, []float64{100, 50}, []float64{50, 25}, 500)
+
+	full := func(x []float64) WDLModel {
+		return WDLModel{A: [4]float64{x[0], x[1], x[2], x[3]}, B: [4]float64{x[4], x[5], x[6], x[7]}}
+	}
+	start := []float64{0, 0, 0, x[0], 0, 0, 0, x[1]}
+	step := []float64{x[0] / 4, x[0] / 4, x[0] / 4, x[0] / 4, x[1] / 4, x[1] / 4, x[1] / 4, x[1] / 4}
+
+	// Restarting the simplex from the best point helps avoid premature convergence
+	for restart := 0; restart < 4; restart++ {
+		start = nelderMead(func(x []float64) float64 {
+			model := full(x)
+			return wdlLoss(&model, groups)
+		}, start, step, 3000)
+	}
+
+	return full(start)
+}
+
+// Minimizes f using the Nelder-Mead simplex method
+func nelderMead(f func([]float64) float64, start []float64, step []float64, iterations int) []float64 {
+	n := len(start)
+
+	type vertex struct {
+		x []float64
+		f float64
+	}
+
+	simplex := make([]vertex, n+1)
+	for i := range simplex {
+		x := append([]float64{}, start...)
+		if i > 0 {
+			x[i-1] += step[i-1]
+		}
+		simplex[i] = vertex{x, f(x)}
+	}
+
+	// Returns centroid + t * (centroid - worst)
+	along := func(centroid []float64, worst []float64, t float64) vertex {
+		x := make([]float64, n)
+		for j := range x {
+			x[j] = centroid[j] + t*(centroid[j]-worst[j])
+		}
+		return vertex{x, f(x)}
+	}
+
+	for iter := 0; iter < iterations; iter++ {
+		sort.Slice(simplex, func(i, j int) bool { return simplex[i].f < simplex[j].f })
+
+		// Converged once all vertices are (almost) equally good
+		if simplex[n].f-simplex[0].f < 1e-10 {
+			break
+		}
+
+		centroid := make([]float64, n)
+		for _, v := range simplex[:n] {
+			for j := range centroid {
+				centroid[j] += v.x[j] / float64(n)
+			}
+		}
+
+		worst := simplex[n]
+		reflected := along(centroid, worst.x, 1)
+
+		switch {
+		case reflected.f < simplex[0].f:
+			expanded := along(centroid, worst.x, 2)
+			simplex[n] = ternary(expanded.f < reflected.f, expanded, reflected)
+		case reflected.f < simplex[n-1].f:
+			simplex[n] = reflected
+		default:
+			contracted := along(centroid, worst.x, -0.5)
+			if contracted.f < worst.f {
+				simplex[n] = contracted
+				continue
+			}
+
+			// Shrink towards the best vertex
+			for i := 1; i <= n; i++ {
+				for j := range simplex[i].x {
+					simplex[i].x[j] = simplex[0].x[j] + 0.5*(simplex[i].x[j]-simplex[0].x[j])
+				}
+				simplex[i].f = f(simplex[i].x)
+			}
+		}
+	}
+
+	sort.Slice(simplex, func(i, j int) bool { return simplex[i].f < simplex[j].f })
+	return simplex[0].x
+}
+
+// Entry point for `maelstrom fitwdl`. Fits the WDL model either on an existing
+// data file or on freshly generated self-play games, and prints the
+// coefficients in a form that can be pasted into WDL_MODEL along with the
+// matching NORMALIZE_TO_PAWN_VALUE.
+func RunFitWDL(args []string) error {
+	flags := flag.NewFlagSet("fitwdl", flag.ContinueOnError)
+	dataPath := flags.String("data", "", "read samples from this file instead of playing games")
+	outPath := flags.String("out", "", "save generated self-play data to this file")
+	games := flags.Int("games", 200, "number of self-play games to generate")
+	nodes := flags.Int("nodes", 5000, "nodes per move in self-play games")
+	seed := flags.Int64("seed", 1, "random seed for self-play openings")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var data io.Reader
+	if *dataPath != "" {
+		file, err := os.Open(*dataPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		data = file
+	} else {
+		InitializeEverythingExceptTTable()
+		InitializeTT(64)
+
+		var buf strings.Builder
+		if err := GenerateSelfPlayData(*games, *nodes, *seed, &buf); err != nil {
+			return err
+		}
+		if *outPath != "" {
+			if err := os.WriteFile(*outPath, []byte(buf.String()), 0644); err != nil {
+				return err
+			}
+		}
+		data = strings.NewReader(buf.String())
+	}
+
+	samples, err := ReadWDLSamples(data)
+	if err != nil {
+		return err
+	}
+	if len(samples) == 0 {
+		return fmt.Errorf("no samples to fit")
+	}
+
+	fmt.Fprintf(Output, "fitting WDL model on %d samples\n", len(samples))
+	model := FitWDL(samples)
+	groups := groupByMaterial(samples)
+	fmt.Fprintf(Output, "loss: %.5f (current model: %.5f)\n", wdlLoss(&model, groups), wdlLoss(&WDL_MODEL, groups))
+	fmt.Fprintf(Output, "A: [4]float64{%.2f, %.2f, %.2f, %.2f},\n", model.A[0], model.A[1], model.A[2], model.A[3])
+	fmt.Fprintf(Output, "B: [4]float64{%.2f, %.2f, %.2f, %.2f},\n", model.B[0], model.B[1], model.B[2], model.B[3])
+
+	a, _ := model.Params(WDL_REFERENCE_MATERIAL)
+	fmt.Fprintf(Output, "NORMALIZE_TO_PAWN_VALUE = %d\n", int(math.Round(a)))
+	return nil
+}
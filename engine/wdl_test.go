@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestWDLSumsAndSymmetry(t *testing.T) {
+	for _, material := range []int{10, 17, 40, 58, 78} {
+		prevWin := -1
+		for score := -600; score <= 600; score += 25 {
+			w, d, l := WDL_MODEL.WDL(score, material)
+			if w+d+l != 1000 || d < 0 {
+				t.Errorf("TestWDLSumsAndSymmetry (sum): got %d %d %d for score %d material %d", w, d, l, score, material)
+			}
+
+			w2, _, l2 := WDL_MODEL.WDL(-score, material)
+			if w != l2 || l != w2 {
+				t.Errorf("TestWDLSumsAndSymmetry (mirror): got %d %d, wanted %d %d", w2, l2, l, w)
+			}
+
+			if w < prevWin {
+				t.Errorf("TestWDLSumsAndSymmetry (monotonic): win rate dropped from %d to %d at score %d", prevWin, w, score)
+			}
+			prevWin = w
+		}
+	}
+}
+
+func TestWDLMateScores(t *testing.T) {
+	w, d, l := WDL_MODEL.WDL(WIN_VAL-5, 40)
+	if w != 1000 || d != 0 || l != 0 {
+		t.Errorf("TestWDLMateScores (win): got %d %d %d", w, d, l)
+	}
+
+	w, d, l = WDL_MODEL.WDL(-WIN_VAL+5, 40)
+	if w != 0 || d != 0 || l != 1000 {
+		t.Errorf("TestWDLMateScores (loss): got %d %d %d", w, d, l)
+	}
+}
+
+func TestWDLMaterial(t *testing.T) {
+	b := Board{}
+	b.InitStartPos()
+	if got := WDLMaterial(&b); got != 78 {
+		t.Errorf("TestWDLMaterial (startpos): got %d, wanted %d", got, 78)
+	}
+
+	fen := "8/4k3/2n5/3n4/8/8/4P3/3K4 w - - 0 1"
+	if got := materialFromFEN(fen); got != 7 {
+		t.Errorf("TestWDLMaterial (fen): got %d, wanted %d", got, 7)
+	}
+}
+
+func TestReadWDLSamples(t *testing.T) {
+	data := "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1 | 35 | 0.5\n\n" +
+		"8/4k3/8/8/8/8/4P3/3K4 w - - 0 1 | 420 | 1.0\n"
+
+	samples, err := ReadWDLSamples(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("TestReadWDLSamples: unexpected error %v", err)
+	}
+	if len(samples) != 2 || samples[0].Score != 35 || samples[0].Material != 78 || samples[1].Result != 1 || samples[1].Material != 1 {
+		t.Errorf("TestReadWDLSamples: got %+v", samples)
+	}
+
+	for _, bad := range []string{"8/8/8/8/8/8/8/8 w - - 0 1 | 10", "8/8/8/8/8/8/8/8 w - - 0 1 | x | 1", "8/8/8/8/8/8/8/8 w - - 0 1 | 10 | 0.7"} {
+		if _, err := ReadWDLSamples(strings.NewReader(bad)); err == nil {
+			t.Errorf("TestReadWDLSamples: expected error for %q", bad)
+		}
+	}
+}
+
+func TestFitWDLRecoversModel(t *testing.T) {
+	truth := WDLModel{
+		A: [4]float64{0, 0, 40, 120},
+		B: [4]float64{0, 0, 20, 50},
+	}
+
+	// Sample results from the known model
+	rng := rand.New(rand.NewSource(3))
+	samples := []WDLSample{}
+	for i := 0; i < 4000; i++ {
+		material := WDL_MIN_MATERIAL + rng.Intn(WDL_MAX_MATERIAL-WDL_MIN_MATERIAL+1)
+		score := rng.Intn(800) - 400
+		win := truth.WinProbability(score, material)
+		loss := truth.WinProbability(-score, material)
+
+		result := 0.5
+		if r := rng.Float64(); r < win {
+			result = 1
+		} else if r < win+loss {
+			result = 0
+		}
+		samples = append(samples, WDLSample{Score: score, Result: result, Material: material})
+	}
+
+	fitted := FitWDL(samples)
+	for _, material := range []int{30, 45, 60} {
+		for _, score := range []int{-200, 0, 100, 300} {
+			got := fitted.WinProbability(score, material)
+			want := truth.WinProbability(score, material)
+			if math.Abs(got-want) > 0.05 {
+				t.Errorf("TestFitWDLRecoversModel: win probability at score %d material %d: got %.3f, wanted %.3f", score, material, got, want)
+			}
+		}
+	}
+}
+
+func TestNormalizeScore(t *testing.T) {
+	// The checked-in scale must match the checked-in model
+	a, _ := WDL_MODEL.Params(WDL_REFERENCE_MATERIAL)
+	if int(math.Round(a)) != NORMALIZE_TO_PAWN_VALUE {
+		t.Errorf("TestNormalizeScore (model): a(%d) = %.1f but NORMALIZE_TO_PAWN_VALUE is %d", WDL_REFERENCE_MATERIAL, a, NORMALIZE_TO_PAWN_VALUE)
+	}
+
+	// +1.00 should be a 50% win at the reference material
+	if p := WDL_MODEL.WinProbability(NORMALIZE_TO_PAWN_VALUE, WDL_REFERENCE_MATERIAL); math.Abs(p-0.5) > 0.01 {
+		t.Errorf("TestNormalizeScore (win rate): got %.3f, wanted 0.5", p)
+	}
+
+	if got := NormalizeScore(NORMALIZE_TO_PAWN_VALUE); got != 100 {
+		t.Errorf("TestNormalizeScore (pawn): got %d, wanted %d", got, 100)
+	}
+	if got := NormalizeScore(-2 * NORMALIZE_TO_PAWN_VALUE); got != -200 {
+		t.Errorf("TestNormalizeScore (negative): got %d, wanted %d", got, -200)
+	}
+	if got := NormalizeScore(WIN_VAL - 3); got != WIN_VAL-3 {
+		t.Errorf("TestNormalizeScore (mate): got %d, wanted %d", got, WIN_VAL-3)
+	}
+}
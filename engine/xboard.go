@@ -0,0 +1,337 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// XBOARD
+//
+//	Front-end for the Chess Engine Communication Protocol (CECP) spoken by XBoard, WinBoard and
+//	older tournament managers. Unlike UCI the engine keeps track of the game itself: moves are
+//	sent one at a time, and the engine plays whenever it is its turn and it is not in force mode.
+//	Searches run through the same engine, time manager and search controller as UCI.
+type XBoardManager struct {
+	Engine          *Engine
+	SearchThread    *Searcher
+	forceMode       bool        // Only record moves, never start thinking
+	engineColor     Color       // Side played by the engine outside of force mode
+	post            atomic.Bool // Print thinking output
+	analyzing       bool
+	movesPerSession int64 // Moves per time control from `level`, 0 for the whole game
+	baseTime        int64 // Time per session from `level`, in milliseconds
+	increment       int64 // Increment from `level`, in milliseconds
+	moveTime        int64 // Fixed time per move from `st`, in milliseconds
+	maxDepth        int64 // Depth limit from `sd`
+	engineTime      int64 // Our clock from `time`, in milliseconds
+	opponentTime    int64 // Opponent clock from `otim`, in milliseconds
+	controller      SearchController
+}
+
+// Scores of mate in N are reported as XBOARD_MATE_SCORE + N
+const XBOARD_MATE_SCORE = 100000
+
+func (xb *XBoardManager) Initialize() {
+	InitializeEverythingExceptTTable()
+	if xb.Engine == nil {
+		xb.Engine = DefaultEngine
+	}
+	xb.Engine.TT.Resize(DEFAULT_HASH)
+	xb.Engine.Timer.SetMoveOverhead(DEFAULT_MOVE_OVERHEAD)
+	xb.SearchThread = xb.Engine.MainSearcher()
+	xb.SearchThread.Info.Quiet = true
+	xb.SearchThread.Info.Progress = xb.printThinking
+	xb.newGame()
+}
+
+// Sets up the starting position with the engine playing black
+func (xb *XBoardManager) newGame() {
+	xb.SearchThread.Position = NewBoard()
+	xb.SearchThread.Position.InitStartPos()
+	xb.Engine.NewGame()
+	xb.forceMode = false
+	xb.engineColor = BLACK
+	xb.maxDepth = 0
+}
+
+func (xb *XBoardManager) printThinking(a Analysis) {
+	if (!xb.post.Load() && !xb.analyzing) || a.MultiPV != 1 || a.Bound != EXACT {
+		return
+	}
+
+	score := a.Score.Centipawns
+	if a.Score.Mate > 0 {
+		score = XBOARD_MATE_SCORE + a.Score.Mate
+	} else if a.Score.Mate < 0 {
+		score = -XBOARD_MATE_SCORE + a.Score.Mate
+	}
+	fmt.Fprintf(Output, "%d %d %d %d %s\n", a.Depth, score, a.Time.Milliseconds()/10, a.Nodes, strings.Trim(fmt.Sprint(a.PV), "[]"))
+}
+
+// Runs a single command. Returns false on quit.
+func (xb *XBoardManager) HandleCommand(command string) bool {
+	words := strings.Fields(command)
+	if len(words) == 0 {
+		return true
+	}
+
+	switch words[0] {
+	case "xboard", "accepted", "rejected", "random", "hard", "easy", "computer", "name", "rating", "ics", "cores", "egtpath", "option", ".":
+		// Nothing to do
+	case "quit":
+		xb.controller.StopAndWait()
+		return false
+	case "protover":
+		fmt.Fprintf(Output, "feature myname=\"Maelstrom %s\" ping=1 setboard=1 playother=1 usermove=1 time=1 draw=0 sigint=0 sigterm=0 reuse=1 analyze=1 colors=0 san=0 memory=1 done=1\n", VERSION)
+	case "ping":
+		fmt.Fprintln(Output, "pong "+strings.Join(words[1:], " "))
+	case "new":
+		xb.controller.StopAndWait()
+		xb.newGame()
+		xb.restartAnalysis()
+	case "force":
+		xb.controller.StopAndWait()
+		xb.forceMode = true
+	case "go":
+		xb.controller.StopAndWait()
+		xb.forceMode = false
+		xb.engineColor = xb.SearchThread.Position.turn
+		xb.think()
+	case "playother":
+		xb.controller.StopAndWait()
+		xb.forceMode = false
+		xb.engineColor = ReverseColor(xb.SearchThread.Position.turn)
+	case "?":
+		// Move now with the best move found so far
+		xb.Engine.Timer.Stop()
+	case "usermove":
+		if len(words) < 2 {
+			fmt.Fprintln(Output, "Error (missing move): usermove")
+			return true
+		}
+		xb.userMove(words[1])
+	case "level":
+		xb.level(words[1:])
+	case "st":
+		xb.moveTime = xb.parseNumber(words, 1000)
+	case "sd":
+		xb.maxDepth = xb.parseNumber(words, 1)
+	case "time":
+		xb.engineTime = xb.parseNumber(words, 10)
+	case "otim":
+		xb.opponentTime = xb.parseNumber(words, 10)
+	case "memory":
+		if mb := xb.parseNumber(words, 1); mb > 0 {
+			xb.controller.StopAndWait()
+			xb.Engine.TT.Resize(Clamp(int(mb), 1, MAX_HASH))
+			xb.restartAnalysis()
+		}
+	case "post":
+		xb.post.Store(true)
+	case "nopost":
+		xb.post.Store(false)
+	case "analyze":
+		xb.controller.StopAndWait()
+		xb.analyzing = true
+		xb.restartAnalysis()
+	case "exit":
+		xb.controller.StopAndWait()
+		xb.analyzing = false
+	case "undo", "remove":
+		xb.controller.StopAndWait()
+		plies := ternary(words[0] == "undo", 1, 2)
+		for i := 0; i < plies && len(xb.SearchThread.Position.history) > 0; i++ {
+			xb.SearchThread.Position.Undo()
+		}
+		xb.restartAnalysis()
+	case "setboard":
+		xb.setBoard(strings.Join(words[1:], " "))
+	case "result":
+		xb.controller.StopAndWait()
+		xb.forceMode = true
+	default:
+		// Protocol version 1 GUIs send moves without usermove
+		if IsUCIMoveSyntax(words[0]) {
+			xb.userMove(words[0])
+			return true
+		}
+		fmt.Fprintf(Output, "Error (unknown command): %s\n", words[0])
+	}
+	return true
+}
+
+// Parses the number argument of a command and scales it to our units
+func (xb *XBoardManager) parseNumber(words []string, scale int64) int64 {
+	if len(words) < 2 {
+		fmt.Fprintf(Output, "Error (missing argument): %s\n", words[0])
+		return 0
+	}
+	value, err := strconv.ParseFloat(words[1], 64)
+	if err != nil || value < 0 {
+		fmt.Fprintf(Output, "Error (bad argument): %s\n", strings.Join(words, " "))
+		return 0
+	}
+	return int64(value * float64(scale))
+}
+
+// Parses `level MPS BASE INC`, where BASE is minutes or minutes:seconds and
+// INC is in seconds
+func (xb *XBoardManager) level(args []string) {
+	if len(args) != 3 {
+		fmt.Fprintln(Output, "Error (bad arguments): level")
+		return
+	}
+
+	mps, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || mps < 0 {
+		fmt.Fprintln(Output, "Error (bad moves per session): level")
+		return
+	}
+
+	minutes, seconds, hasSeconds := strings.Cut(args[1], ":")
+	m, err := strconv.ParseInt(minutes, 10, 64)
+	base := m * 60000
+	if err == nil && hasSeconds {
+		var s int64
+		s, err = strconv.ParseInt(seconds, 10, 64)
+		base += s * 1000
+	}
+	if err != nil || base < 0 {
+		fmt.Fprintln(Output, "Error (bad base time): level")
+		return
+	}
+
+	inc, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || inc < 0 {
+		fmt.Fprintln(Output, "Error (bad increment): level")
+		return
+	}
+
+	xb.movesPerSession = mps
+	xb.baseTime = base
+	xb.increment = int64(inc * 1000)
+	xb.moveTime = 0
+	xb.engineTime = base
+	xb.opponentTime = base
+}
+
+func (xb *XBoardManager) userMove(move string) {
+	xb.controller.StopAndWait()
+
+	b := xb.SearchThread.Position
+	mv, err := LegalMoveFromUCI(move, b)
+	if errors.Is(err, ErrMalformedMove) {
+		fmt.Fprintf(Output, "Error (malformed move): %s\n", move)
+		return
+	} else if err != nil {
+		fmt.Fprintf(Output, "Illegal move: %s\n", move)
+		return
+	}
+	b.MakeMove(mv)
+
+	if result, over := xboardResult(b); over {
+		fmt.Fprintln(Output, result)
+	}
+
+	if xb.analyzing {
+		xb.restartAnalysis()
+	} else if !xb.forceMode && b.turn == xb.engineColor {
+		xb.think()
+	}
+}
+
+func (xb *XBoardManager) setBoard(fen string) {
+	xb.controller.StopAndWait()
+	b, err := ParseFEN(fen)
+	if err != nil {
+		fmt.Fprintf(Output, "tellusererror Illegal position: %v\n", err)
+		return
+	}
+
+	xb.SearchThread.Position = b
+	xb.restartAnalysis()
+}
+
+// Starts searching for the engine's move, which is played once the search ends
+func (xb *XBoardManager) think() {
+	s := xb.SearchThread
+	if _, over := xboardResult(s.Position); over {
+		return
+	}
+
+	// Moves left in the session, counting this one
+	movesToGo := int64(0)
+	if xb.movesPerSession > 0 {
+		movesToGo = xb.movesPerSession - int64(len(s.Position.history)/2)%xb.movesPerSession
+	}
+
+	wtime := ternary(s.Position.turn == WHITE, xb.engineTime, xb.opponentTime)
+	btime := ternary(s.Position.turn == WHITE, xb.opponentTime, xb.engineTime)
+	timer := xb.Engine.Timer
+	// A depth limit from `sd` comes on top of the clock, which Calculate would drop
+	timer.Calculate(s.Position.turn, wtime, btime, xb.increment, xb.increment, movesToGo, 0, 0, xb.moveTime, false)
+	timer.LimitSearch(xb.maxDepth, 0)
+
+	xb.controller.Start(func(ctx context.Context) {
+		timer.StopOn(ctx.Done())
+		defer timer.StopOn(nil)
+
+		mv := s.SearchPosition()
+		timer.FinishSearch(&s.Info)
+
+		// Commands that interrupt the search take the move back from us
+		if ctx.Err() != nil || mv.IsEmpty() {
+			return
+		}
+
+		s.Position.MakeMove(mv)
+		fmt.Fprintln(Output, "move "+mv.ToUCI())
+		if result, over := xboardResult(s.Position); over {
+			fmt.Fprintln(Output, result)
+		}
+	})
+}
+
+// Starts an infinite search of the current position when in analyze mode
+func (xb *XBoardManager) restartAnalysis() {
+	if !xb.analyzing {
+		return
+	}
+
+	xb.controller.Start(func(ctx context.Context) {
+		timer := xb.Engine.Timer
+		timer.StopOn(ctx.Done())
+		defer timer.StopOn(nil)
+
+		if len(xb.SearchThread.Position.GenerateLegalMoves()) == 0 {
+			return
+		}
+		timer.Calculate(xb.SearchThread.Position.turn, 0, 0, 0, 0, 0, 0, 0, 0, true)
+		xb.SearchThread.SearchPosition()
+	})
+}
+
+// Returns the result claim if the game is over by the rules
+func xboardResult(b *Board) (string, bool) {
+	outcome := b.Outcome()
+	reason := ""
+	switch outcome.Termination {
+	case ONGOING:
+		return "", false
+	case CHECKMATE:
+		reason = ternary(outcome.Winner == WHITE, "White mates", "Black mates")
+	case STALEMATE:
+		reason = "Stalemate"
+	case INSUFFICIENT_MATERIAL:
+		reason = "Insufficient material"
+	case THREEFOLD_REPETITION, FIVEFOLD_REPETITION:
+		reason = "Draw by repetition"
+	case FIFTY_MOVES, SEVENTY_FIVE_MOVES:
+		reason = "50 move rule"
+	}
+	return fmt.Sprintf("%s {%s}", outcome.Result(), reason), true
+}
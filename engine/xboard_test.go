@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"testing"
+)
+
+func newTestXBoard() *XBoardManager {
+	xb := &XBoardManager{Engine: NewEngine(1)}
+	xb.Initialize()
+	xb.HandleCommand("memory 1")
+	return xb
+}
+
+func TestXBoardLevel(t *testing.T) {
+	xb := newTestXBoard()
+
+	xb.HandleCommand("level 40 2:30 1.5")
+	if xb.movesPerSession != 40 || xb.baseTime != 150000 || xb.increment != 1500 {
+		t.Errorf("TestXBoardLevel: got %d moves in %dms + %dms", xb.movesPerSession, xb.baseTime, xb.increment)
+	}
+
+	xb.HandleCommand("level 0 5 0")
+	if xb.movesPerSession != 0 || xb.baseTime != 300000 || xb.increment != 0 {
+		t.Errorf("TestXBoardLevel: got %d moves in %dms + %dms", xb.movesPerSession, xb.baseTime, xb.increment)
+	}
+
+	// Bad arguments keep the previous time control
+	xb.HandleCommand("level 0 x 0")
+	if xb.baseTime != 300000 {
+		t.Errorf("TestXBoardLevel (bad): got base time %dms", xb.baseTime)
+	}
+
+	xb.HandleCommand("st 2")
+	xb.HandleCommand("time 1234")
+	if xb.moveTime != 2000 || xb.engineTime != 12340 {
+		t.Errorf("TestXBoardLevel (st/time): got move time %dms and clock %dms", xb.moveTime, xb.engineTime)
+	}
+}
+
+func TestXBoardDepthWithClock(t *testing.T) {
+	xb := newTestXBoard()
+
+	// The depth limit must not turn off the clock
+	xb.HandleCommand("level 40 5 0")
+	xb.HandleCommand("sd 4")
+	xb.HandleCommand("time 30000")
+	xb.HandleCommand("otim 30000")
+	xb.HandleCommand("go")
+	xb.controller.Wait()
+
+	timer := xb.Engine.Timer
+	if timer.softLimit >= INF_TIME || timer.hardLimit >= INF_TIME || timer.maxDepth != 4 {
+		t.Errorf("TestXBoardDepthWithClock: got soft limit %d, hard limit %d and depth %d", timer.softLimit, timer.hardLimit, timer.maxDepth)
+	}
+}
+
+func TestXBoardMoves(t *testing.T) {
+	xb := newTestXBoard()
+	start := xb.SearchThread.Position.zobrist
+
+	xb.HandleCommand("force")
+	xb.HandleCommand("usermove e2e4")
+	xb.HandleCommand("e7e5")
+	xb.HandleCommand("usermove e1e3")
+	if got := len(xb.SearchThread.Position.history); got != 2 {
+		t.Fatalf("TestXBoardMoves: got %d moves played, wanted %d", got, 2)
+	}
+
+	xb.HandleCommand("remove")
+	if xb.SearchThread.Position.zobrist != start {
+		t.Errorf("TestXBoardMoves (remove): not back at the start position")
+	}
+
+	xb.HandleCommand("setboard 8/8/8/8 w - - 0 1")
+	if xb.SearchThread.Position.zobrist != start {
+		t.Errorf("TestXBoardMoves (setboard): invalid fen changed the position")
+	}
+
+	// The engine answers once it is its turn
+	xb.HandleCommand("setboard 6k1/5ppp/8/8/8/8/8/R5K1 b - - 0 1")
+	xb.HandleCommand("sd 3")
+	xb.HandleCommand("playother")
+	xb.HandleCommand("usermove g8h8")
+	xb.controller.Wait()
+	if got := xb.SearchThread.Position.history; len(got) != 2 || got[1].move.ToUCI() != "a1a8" {
+		t.Errorf("TestXBoardMoves (engine move): got history %v", got)
+	}
+	if result, over := xboardResult(xb.SearchThread.Position); !over || result != "1-0 {White mates}" {
+		t.Errorf("TestXBoardMoves (result): got %q", result)
+	}
+}
+
+func TestXBoardResult(t *testing.T) {
+	InitializeEverythingExceptTTable()
+
+	tests := []struct {
+		fen  string
+		want string
+	}{
+		{"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", "1/2-1/2 {Stalemate}"},
+		{"R5k1/5ppp/8/8/8/8/8/6K1 b - - 0 1", "1-0 {White mates}"},
+		{"8/8/4k3/8/8/3KN3/8/8 w - - 0 1", "1/2-1/2 {Insufficient material}"},
+		{"8/8/4k3/8/8/3K4/8/R7 w - - 100 80", "1/2-1/2 {50 move rule}"},
+		{"8/8/4k3/8/2n5/3KN3/8/8 w - - 0 1", ""},
+	}
+	for _, test := range tests {
+		b := NewBoard()
+		b.InitFEN(test.fen)
+		if got, _ := xboardResult(b); got != test.want {
+			t.Errorf("TestXBoardResult (%s): got %q, wanted %q", test.fen, got, test.want)
+		}
+	}
+}
@@ -1,11 +1,43 @@
 package main
 
 import (
+	"fmt"
 	"maelstrom/engine"
+	"os"
 )
 
 func main() {
-	uci := engine.UCIManager{}
-	uci.Initialize()
-	uci.UciLoop()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fitwdl":
+			if err := engine.RunFitWDL(os.Args[2:]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		case "calibrate":
+			if err := engine.RunCalibrate(os.Args[2:]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		case "bench":
+			if err := engine.RunBenchCommand(os.Args[2:]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		case "--json":
+			engine.RunProtocol(os.Stdin, true)
+			return
+		case "serve":
+			if err := engine.RunServe(os.Args[2:]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	engine.RunProtocol(os.Stdin, false)
 }